@@ -2,9 +2,29 @@ package elastic
 
 import "context"
 
+// StatusError is returned for error responses of the cluster, so callers can tell authentication
+// errors and invalid queries apart by the HTTP status.
+type StatusError struct {
+	Status  int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
+// Fetch modes that define where the values of a hit are taken from.
+const (
+	FetchModeSource    = "source"
+	FetchModeFields    = "fields"
+	FetchModeDocvalues = "docvalues"
+	FetchModeStored    = "stored"
+)
+
 type Client interface {
-	Count(ctx context.Context, index string, query Query) (int64, error)
+	Count(ctx context.Context, index string, query Query, runtimeMappings map[string]interface{}) (int64, error)
 	Scroll(index string, size int, query Query) ScrollService
+	Mapping(ctx context.Context, index string) ([]Field, error)
 	Stop()
 }
 
@@ -15,7 +35,11 @@ type Query interface {
 type ScrollService interface {
 	Do(ctx context.Context) (SearchResult, error)
 	Clear(ctx context.Context) error
-	FetchSourceContext(includeFields []string) ScrollService
+	FetchSourceContext(includeFields, excludeFields []string) ScrollService
+	FetchFields(mode string, fields []string) ScrollService
+	Fields(fields []string) ScrollService
+	RuntimeMappings(runtimeMappings map[string]interface{}) ScrollService
+	Sort(field string, ascending bool) ScrollService
 }
 
 type SearchResult interface {
@@ -25,4 +49,8 @@ type SearchResult interface {
 
 type SearchHit interface {
 	GetSource() []byte
+	GetFields() []byte
+	GetID() string
+	GetIndex() string
+	GetRouting() string
 }
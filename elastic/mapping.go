@@ -0,0 +1,223 @@
+package elastic
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// Field types of an index mapping that need special handling.
+const (
+	TypeObject = "object"
+	TypeNested = "nested"
+)
+
+// Field is a field of an index mapping. Object and nested fields have their properties as Fields.
+// A mapping does not tell whether a field holds arrays, Array is set with MarkArrays.
+type Field struct {
+	Name   string
+	Type   string
+	Fields []Field
+	Array  bool
+}
+
+// IsGroup reports whether the field is an object or nested field with sub fields.
+func (f Field) IsGroup() bool {
+	return f.Type == TypeObject || f.Type == TypeNested
+}
+
+// ParseMapping merges the properties of all indices of a get mapping response into one
+// list of fields sorted by name. If indices define the same field, the first definition wins.
+func ParseMapping(response map[string]interface{}) []Field {
+	indices := make([]string, 0, len(response))
+	for index := range response {
+		indices = append(indices, index)
+	}
+	sort.Strings(indices)
+
+	var fields []Field
+	for _, index := range indices {
+		indexMapping, _ := response[index].(map[string]interface{})
+		mappings, _ := indexMapping["mappings"].(map[string]interface{})
+		properties, _ := mappings["properties"].(map[string]interface{})
+		fields = mergeFields(fields, parseProperties(properties))
+	}
+
+	return fields
+}
+
+func parseProperties(properties map[string]interface{}) []Field {
+	fields := make([]Field, 0, len(properties))
+
+	for name, definition := range properties {
+		definition, _ := definition.(map[string]interface{})
+		fieldType, _ := definition["type"].(string)
+
+		// objects have no explicit type in a mapping
+		if fieldType == "" {
+			fieldType = TypeObject
+		}
+
+		field := Field{Name: name, Type: fieldType}
+		if subProperties, ok := definition["properties"].(map[string]interface{}); ok {
+			field.Fields = parseProperties(subProperties)
+		}
+
+		fields = append(fields, field)
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Name < fields[j].Name
+	})
+
+	return fields
+}
+
+func mergeFields(fields, other []Field) []Field {
+	for _, field := range other {
+		found := false
+		for i := range fields {
+			if fields[i].Name == field.Name {
+				found = true
+				if fields[i].IsGroup() && field.IsGroup() {
+					fields[i].Fields = mergeFields(fields[i].Fields, field.Fields)
+				}
+				break
+			}
+		}
+		if !found {
+			fields = append(fields, field)
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Name < fields[j].Name
+	})
+
+	return fields
+}
+
+// AddField adds a field with a dotted name like "user.name" to the mapping, creating object fields on the way.
+func AddField(fields []Field, name, fieldType string) []Field {
+	parent, child, found := strings.Cut(name, ".")
+	if !found {
+		return mergeFields(fields, []Field{{Name: name, Type: fieldType}})
+	}
+
+	return mergeFields(fields, []Field{{
+		Name:   parent,
+		Type:   TypeObject,
+		Fields: AddField(nil, child, fieldType),
+	}})
+}
+
+// FilterFields keeps only the fields that match one of the given include patterns. Object fields are
+// kept completely if they match a pattern themselves, otherwise only with their matching sub fields.
+func FilterFields(fields []Field, includes []string) []Field {
+	if len(includes) == 0 {
+		return fields
+	}
+	return filterFields(fields, includes, "")
+}
+
+func filterFields(fields []Field, includes []string, prefix string) []Field {
+	var filtered []Field
+
+	for _, field := range fields {
+		name := prefix + field.Name
+		if matchesAny(name, includes) {
+			filtered = append(filtered, field)
+			continue
+		}
+		if field.IsGroup() {
+			if children := filterFields(field.Fields, includes, name+"."); len(children) > 0 {
+				field.Fields = children
+				filtered = append(filtered, field)
+			}
+		}
+	}
+
+	return filtered
+}
+
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if pattern == name {
+			return true
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// LeafFields returns all fields that are not objects with their full dotted name, e.g. "user.name".
+// Nested fields and objects marked as Array are returned as a single leaf since they can hold several
+// objects per document.
+func LeafFields(fields []Field) []Field {
+	return leafFields(fields, "")
+}
+
+func leafFields(fields []Field, prefix string) []Field {
+	var leaves []Field
+
+	for _, field := range fields {
+		if field.Type == TypeObject && !field.Array {
+			leaves = append(leaves, leafFields(field.Fields, prefix+field.Name+".")...)
+			continue
+		}
+		field.Name = prefix + field.Name
+		leaves = append(leaves, field)
+	}
+
+	return leaves
+}
+
+// MarkArrays sets Array for all fields that match one of the given patterns, e.g. "tags" or "user.*".
+func MarkArrays(fields []Field, patterns []string) []Field {
+	if len(patterns) == 0 {
+		return fields
+	}
+	return markArrays(fields, patterns, "")
+}
+
+func markArrays(fields []Field, patterns []string, prefix string) []Field {
+	marked := make([]Field, len(fields))
+
+	for i, field := range fields {
+		name := prefix + field.Name
+		field.Array = field.Array || matchesAny(name, patterns)
+		if field.IsGroup() {
+			field.Fields = markArrays(field.Fields, patterns, name+".")
+		}
+		marked[i] = field
+	}
+
+	return marked
+}
+
+// RemoveFields drops all fields that match one of the given exclude patterns.
+func RemoveFields(fields []Field, excludes []string) []Field {
+	if len(excludes) == 0 {
+		return fields
+	}
+	return removeFields(fields, excludes, "")
+}
+
+func removeFields(fields []Field, excludes []string, prefix string) []Field {
+	var kept []Field
+
+	for _, field := range fields {
+		name := prefix + field.Name
+		if matchesAny(name, excludes) {
+			continue
+		}
+		if field.IsGroup() {
+			field.Fields = removeFields(field.Fields, excludes, name+".")
+		}
+		kept = append(kept, field)
+	}
+
+	return kept
+}
@@ -0,0 +1,87 @@
+package elastic
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMapping(t *testing.T) {
+	response := map[string]interface{}{
+		"logs-1": map[string]interface{}{
+			"mappings": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"@timestamp": map[string]interface{}{"type": "date"},
+					"user": map[string]interface{}{
+						"properties": map[string]interface{}{
+							"name": map[string]interface{}{"type": "keyword"},
+						},
+					},
+				},
+			},
+		},
+		"logs-2": map[string]interface{}{
+			"mappings": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"user": map[string]interface{}{
+						"properties": map[string]interface{}{
+							"id": map[string]interface{}{"type": "long"},
+						},
+					},
+					"tags": map[string]interface{}{
+						"type": "nested",
+						"properties": map[string]interface{}{
+							"key": map[string]interface{}{"type": "keyword"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	fields := ParseMapping(response)
+	want := []Field{
+		{Name: "@timestamp", Type: "date"},
+		{Name: "tags", Type: TypeNested, Fields: []Field{{Name: "key", Type: "keyword"}}},
+		{Name: "user", Type: TypeObject, Fields: []Field{{Name: "id", Type: "long"}, {Name: "name", Type: "keyword"}}},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Fatalf("ParseMapping() = %v, want %v", fields, want)
+	}
+
+	leaves := LeafFields(FilterFields(fields, []string{"@timestamp", "user.*"}))
+	wantLeaves := []Field{
+		{Name: "@timestamp", Type: "date"},
+		{Name: "user.id", Type: "long"},
+		{Name: "user.name", Type: "keyword"},
+	}
+	if !reflect.DeepEqual(leaves, wantLeaves) {
+		t.Errorf("LeafFields() = %v, want %v", leaves, wantLeaves)
+	}
+
+	leaves = LeafFields(RemoveFields(AddField(fields, "user.age", "long"), []string{"tags", "user.name"}))
+	wantLeaves = []Field{
+		{Name: "@timestamp", Type: "date"},
+		{Name: "user.age", Type: "long"},
+		{Name: "user.id", Type: "long"},
+	}
+	if !reflect.DeepEqual(leaves, wantLeaves) {
+		t.Errorf("LeafFields() = %v, want %v", leaves, wantLeaves)
+	}
+
+	leaves = LeafFields(MarkArrays(ParseMapping(response), []string{"user.name", "@timestamp"}))
+	wantLeaves = []Field{
+		{Name: "@timestamp", Type: "date", Array: true},
+		{Name: "tags", Type: TypeNested, Fields: []Field{{Name: "key", Type: "keyword"}}},
+		{Name: "user.id", Type: "long"},
+		{Name: "user.name", Type: "keyword", Array: true},
+	}
+	if !reflect.DeepEqual(leaves, wantLeaves) {
+		t.Errorf("LeafFields() = %v, want %v", leaves, wantLeaves)
+	}
+
+	// objects that hold arrays are single leaves like nested fields
+	leaves = LeafFields(MarkArrays(ParseMapping(response), []string{"user"}))
+	if len(leaves) != 3 || leaves[2].Name != "user" || !leaves[2].Array {
+		t.Errorf("LeafFields() = %v, want user as single leaf", leaves)
+	}
+}
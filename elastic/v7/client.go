@@ -2,12 +2,16 @@ package v7
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/olivere/elastic/v7"
+
+	elasticsearch "github.com/pteich/elastic-query-export/elastic"
 )
 
 type Client struct {
@@ -16,6 +20,9 @@ type Client struct {
 
 type ScrollService struct {
 	scroll *elastic.ScrollService
+	source *elastic.SearchSource
+	fields []string
+	sorted bool
 }
 
 type SearchResult struct {
@@ -34,7 +41,21 @@ func NewClient(esOpts []elastic.ClientOptionFunc) (*Client, error) {
 	return &Client{client: client}, nil
 }
 
-func (c *Client) Count(ctx context.Context, index string, query elastic.Query) (int64, error) {
+func (c *Client) Count(ctx context.Context, index string, query elastic.Query, runtimeMappings map[string]interface{}) (int64, error) {
+	// the count API does not accept runtime mappings, so a search without hits is used instead
+	if len(runtimeMappings) > 0 {
+		source := elastic.NewSearchSource().
+			Query(query).
+			RuntimeMappings(runtimeMappings).
+			Size(0).
+			TrackTotalHits(true)
+		result, err := c.client.Search(index).SearchSource(source).Do(ctx)
+		if err != nil {
+			return 0, err
+		}
+		return result.TotalHits(), nil
+	}
+
 	count, err := c.client.Count(index).Query(query).Do(ctx)
 	if err != nil {
 		return 0, err
@@ -42,9 +63,19 @@ func (c *Client) Count(ctx context.Context, index string, query elastic.Query) (
 	return count, nil
 }
 
+func (c *Client) Mapping(ctx context.Context, index string) ([]elasticsearch.Field, error) {
+	mapping, err := c.client.GetMapping().Index(index).Do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return elasticsearch.ParseMapping(mapping), nil
+}
+
 func (c *Client) Scroll(index string, size int, query elastic.Query) *ScrollService {
+	source := elastic.NewSearchSource().Query(query)
 	return &ScrollService{
-		scroll: c.client.Scroll(index).Size(size).Query(query),
+		scroll: c.client.Scroll(index).Size(size).SearchSource(source),
+		source: source,
 	}
 }
 
@@ -52,7 +83,37 @@ func (c *Client) Stop() {
 	c.client.Stop()
 }
 
+// Body returns the body of the search request that starts the scroll.
+func (s *ScrollService) Body() (map[string]interface{}, error) {
+	source, err := s.source.Source()
+	if err != nil {
+		return nil, err
+	}
+	body, ok := source.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected search source %T", source)
+	}
+
+	// olivere/elastic has no support for the fields API, so the fields are added to the body
+	if len(s.fields) > 0 {
+		body["fields"] = s.fields
+		if !s.sorted {
+			body["sort"] = []interface{}{"_doc"}
+		}
+	}
+	return body, nil
+}
+
 func (s *ScrollService) Do(ctx context.Context) (*SearchResult, error) {
+	if len(s.fields) > 0 {
+		body, err := s.Body()
+		if err != nil {
+			return nil, err
+		}
+		s.scroll = s.scroll.Body(body)
+		s.fields = nil
+	}
+
 	results, err := s.scroll.Do(ctx)
 	if err != nil {
 		return nil, err
@@ -64,14 +125,48 @@ func (s *ScrollService) Clear(ctx context.Context) error {
 	return s.scroll.Clear(ctx)
 }
 
-func (s *ScrollService) FetchSourceContext(includeFields []string) *ScrollService {
+func (s *ScrollService) FetchSourceContext(includeFields, excludeFields []string) *ScrollService {
 	fsc := elastic.NewFetchSourceContext(true)
 	for _, field := range includeFields {
 		fsc.Include(field)
 	}
-	return &ScrollService{
-		scroll: s.scroll.FetchSourceContext(fsc),
+	for _, field := range excludeFields {
+		fsc.Exclude(field)
 	}
+	s.source = s.source.FetchSourceContext(fsc)
+	return s
+}
+
+// FetchFields loads the given fields from the fields API, docvalue_fields or stored_fields
+// instead of _source. Use "*" to get all fields.
+func (s *ScrollService) FetchFields(mode string, fields []string) *ScrollService {
+	s.source = s.source.FetchSource(false)
+	switch mode {
+	case elasticsearch.FetchModeFields:
+		s.fields = append(s.fields, fields...)
+	case elasticsearch.FetchModeDocvalues:
+		s.source = s.source.DocvalueFields(fields...)
+	case elasticsearch.FetchModeStored:
+		s.source = s.source.StoredFields(fields...)
+	}
+	return s
+}
+
+// Fields requests additional values from the fields API next to _source.
+func (s *ScrollService) Fields(fields []string) *ScrollService {
+	s.fields = append(s.fields, fields...)
+	return s
+}
+
+func (s *ScrollService) RuntimeMappings(runtimeMappings map[string]interface{}) *ScrollService {
+	s.source = s.source.RuntimeMappings(runtimeMappings)
+	return s
+}
+
+func (s *ScrollService) Sort(field string, ascending bool) *ScrollService {
+	s.source = s.source.Sort(field, ascending)
+	s.sorted = true
+	return s
 }
 
 func (r *SearchResult) Hits() []*SearchHit {
@@ -86,6 +181,26 @@ func (h *SearchHit) GetSource() []byte {
 	return h.hit.Source
 }
 
+func (h *SearchHit) GetFields() []byte {
+	if len(h.hit.Fields) == 0 {
+		return nil
+	}
+	fields, _ := json.Marshal(h.hit.Fields)
+	return fields
+}
+
+func (h *SearchHit) GetID() string {
+	return h.hit.Id
+}
+
+func (h *SearchHit) GetIndex() string {
+	return h.hit.Index
+}
+
+func (h *SearchHit) GetRouting() string {
+	return h.hit.Routing
+}
+
 func (h *SearchHit) Unwrap() *elastic.SearchHit {
 	return h.hit
 }
@@ -122,6 +237,10 @@ func SetSniff(enabled bool) elastic.ClientOptionFunc {
 	return elastic.SetSniff(enabled)
 }
 
+func SetHealthcheck(enabled bool) elastic.ClientOptionFunc {
+	return elastic.SetHealthcheck(enabled)
+}
+
 func SetHealthcheckInterval(interval time.Duration) elastic.ClientOptionFunc {
 	return elastic.SetHealthcheckInterval(interval)
 }
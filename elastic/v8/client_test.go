@@ -0,0 +1,90 @@
+package v8
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+func TestScrollService_body(t *testing.T) {
+	query := NewQueryStringQuery("level:error")
+
+	tests := []struct {
+		name   string
+		scroll func(s *ScrollService) *ScrollService
+		want   string
+	}{
+		{"source", func(s *ScrollService) *ScrollService {
+			return s
+		}, `{"query":{"query_string":{"query":"level:error"}}}`},
+		{"source fields", func(s *ScrollService) *ScrollService {
+			return s.FetchSourceContext([]string{"a"}, []string{"b"})
+		}, `{"_source":{"excludes":["b"],"includes":["a"]},"query":{"query_string":{"query":"level:error"}}}`},
+		{"fields", func(s *ScrollService) *ScrollService {
+			return s.FetchFields(elastic.FetchModeFields, []string{"a", "b"})
+		}, `{"_source":false,"fields":["a","b"],"query":{"query_string":{"query":"level:error"}}}`},
+		{"docvalues", func(s *ScrollService) *ScrollService {
+			return s.FetchFields(elastic.FetchModeDocvalues, []string{"a"})
+		}, `{"_source":false,"docvalue_fields":["a"],"query":{"query_string":{"query":"level:error"}}}`},
+		{"stored", func(s *ScrollService) *ScrollService {
+			return s.FetchFields(elastic.FetchModeStored, []string{"*"})
+		}, `{"_source":false,"query":{"query_string":{"query":"level:error"}},"stored_fields":["*"]}`},
+		{"runtime fields", func(s *ScrollService) *ScrollService {
+			return s.RuntimeMappings(map[string]interface{}{"day": map[string]interface{}{"type": "keyword"}}).
+				Fields([]string{"day"}).
+				Sort("@timestamp", false)
+		}, `{"fields":["day"],"query":{"query_string":{"query":"level:error"}},"runtime_mappings":{"day":{"type":"keyword"}},"sort":[{"@timestamp":{"order":"desc"}}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.scroll((&Client{}).Scroll("logs", 10, query))
+			body, err := json.Marshal(s.Body())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != tt.want {
+				t.Errorf("Body() = %s, want %s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrollService_Do(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"_scroll_id":"scroll-1","hits":{"total":{"value":2},"hits":[
+			{"_id":"1","_index":"logs","_routing":"r","fields":{"a":[1]}},
+			{"_id":"2","_index":"logs"}]}}`)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(NewConfig(server.URL, "", "", false, http.DefaultClient))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := client.Scroll("logs", 10, NewMatchAllQuery()).
+		FetchFields(elastic.FetchModeFields, []string{"a"}).
+		Do(context.Background())
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	// the second document has none of the requested fields and is kept anyway
+	hits := result.Hits()
+	if len(hits) != 2 || result.Total() != 2 {
+		t.Fatalf("Do() returned %d hits of %d, want 2", len(hits), result.Total())
+	}
+	if hits[0].GetID() != "1" || hits[0].GetRouting() != "r" || string(hits[0].GetFields()) != `{"a":[1]}` {
+		t.Errorf("unexpected first hit %+v", hits[0])
+	}
+	if hits[1].GetID() != "2" || hits[1].GetIndex() != "logs" || hits[1].GetFields() != nil {
+		t.Errorf("unexpected second hit %+v", hits[1])
+	}
+}
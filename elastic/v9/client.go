@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -26,6 +25,12 @@ type ScrollService struct {
 	size          int
 	query         map[string]interface{}
 	includeFields []string
+	excludeFields []string
+	fetchMode     string
+	fetchFields   []string
+	fields        []string
+	runtime       map[string]interface{}
+	sort          []interface{}
 	scrollID      string
 	scrollTime    time.Duration
 }
@@ -36,7 +41,11 @@ type SearchResult struct {
 }
 
 type SearchHit struct {
-	source []byte
+	source  []byte
+	fields  []byte
+	id      string
+	index   string
+	routing string
 }
 
 type QueryBuilder struct {
@@ -51,15 +60,30 @@ func NewClient(cfg elasticsearch.Config) (*Client, error) {
 	return &Client{client: client}, nil
 }
 
-func (c *Client) Count(ctx context.Context, index string, query elastic.Query) (int64, error) {
+func (c *Client) Count(ctx context.Context, index string, query elastic.Query, runtimeMappings map[string]interface{}) (int64, error) {
 	var buf bytes.Buffer
+	queryBody := make(map[string]interface{})
 	if query != nil {
 		queryMap := query.Build()
 		if len(queryMap) > 0 {
-			queryBody := map[string]interface{}{"query": queryMap}
-			if err := json.NewEncoder(&buf).Encode(queryBody); err != nil {
-				return 0, err
-			}
+			queryBody["query"] = queryMap
+		}
+	}
+
+	// the count API does not accept runtime mappings, so a search without hits is used instead
+	if len(runtimeMappings) > 0 {
+		queryBody["runtime_mappings"] = runtimeMappings
+		queryBody["size"] = 0
+		queryBody["track_total_hits"] = true
+		if err := json.NewEncoder(&buf).Encode(queryBody); err != nil {
+			return 0, err
+		}
+		return c.searchTotal(ctx, index, &buf)
+	}
+
+	if len(queryBody) > 0 {
+		if err := json.NewEncoder(&buf).Encode(queryBody); err != nil {
+			return 0, err
 		}
 	}
 
@@ -75,7 +99,7 @@ func (c *Client) Count(ctx context.Context, index string, query elastic.Query) (
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return 0, errors.New(res.String())
+		return 0, &elastic.StatusError{Status: res.StatusCode, Message: res.String()}
 	}
 
 	var resp map[string]interface{}
@@ -86,6 +110,59 @@ func (c *Client) Count(ctx context.Context, index string, query elastic.Query) (
 	return int64(resp["count"].(float64)), nil
 }
 
+func (c *Client) searchTotal(ctx context.Context, index string, body *bytes.Buffer) (int64, error) {
+	req := esapi.SearchRequest{
+		Index: []string{index},
+		Body:  body,
+	}
+
+	res, err := req.Do(ctx, c.client)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return 0, &elastic.StatusError{Status: res.StatusCode, Message: res.String()}
+	}
+
+	var resp struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return 0, err
+	}
+
+	return resp.Hits.Total.Value, nil
+}
+
+func (c *Client) Mapping(ctx context.Context, index string) ([]elastic.Field, error) {
+	req := esapi.IndicesGetMappingRequest{
+		Index: []string{index},
+	}
+
+	res, err := req.Do(ctx, c.client)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, &elastic.StatusError{Status: res.StatusCode, Message: res.String()}
+	}
+
+	var resp map[string]interface{}
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, err
+	}
+
+	return elastic.ParseMapping(resp), nil
+}
+
 func (c *Client) Scroll(index string, size int, query elastic.Query) *ScrollService {
 	return &ScrollService{
 		client:     c.client,
@@ -98,19 +175,54 @@ func (c *Client) Scroll(index string, size int, query elastic.Query) *ScrollServ
 
 func (c *Client) Stop() {}
 
-func (s *ScrollService) Do(ctx context.Context) (*SearchResult, error) {
-	var buf bytes.Buffer
-	var res *esapi.Response
-	var err error
-
+// body returns the search request body with the query, the fetched fields, runtime mappings and sort.
+// Body returns the body of the search request that starts the scroll.
+func (s *ScrollService) Body() map[string]interface{} {
 	queryBody := make(map[string]interface{})
 	if len(s.query) > 0 {
 		queryBody["query"] = s.query
 	}
-	if len(s.includeFields) > 0 {
-		queryBody["_source"] = s.includeFields
+	if len(s.includeFields) > 0 || len(s.excludeFields) > 0 {
+		source := make(map[string]interface{})
+		if len(s.includeFields) > 0 {
+			source["includes"] = s.includeFields
+		}
+		if len(s.excludeFields) > 0 {
+			source["excludes"] = s.excludeFields
+		}
+		queryBody["_source"] = source
 	}
 
+	if len(s.runtime) > 0 {
+		queryBody["runtime_mappings"] = s.runtime
+	}
+	if len(s.sort) > 0 {
+		queryBody["sort"] = s.sort
+	}
+	if len(s.fields) > 0 {
+		queryBody["fields"] = s.fields
+	}
+
+	switch s.fetchMode {
+	case elastic.FetchModeFields:
+		queryBody["_source"] = false
+	case elastic.FetchModeDocvalues:
+		queryBody["docvalue_fields"] = s.fetchFields
+		queryBody["_source"] = false
+	case elastic.FetchModeStored:
+		queryBody["stored_fields"] = s.fetchFields
+		queryBody["_source"] = false
+	}
+
+	return queryBody
+}
+
+func (s *ScrollService) Do(ctx context.Context) (*SearchResult, error) {
+	var buf bytes.Buffer
+	var res *esapi.Response
+	var err error
+
+	queryBody := s.Body()
 	if len(queryBody) > 0 {
 		if err := json.NewEncoder(&buf).Encode(queryBody); err != nil {
 			return nil, err
@@ -140,7 +252,7 @@ func (s *ScrollService) Do(ctx context.Context) (*SearchResult, error) {
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return nil, errors.New(res.String())
+		return nil, &elastic.StatusError{Status: res.StatusCode, Message: res.String()}
 	}
 
 	var resp map[string]interface{}
@@ -168,11 +280,18 @@ func (s *ScrollService) Do(ctx context.Context) (*SearchResult, error) {
 			continue
 		}
 
-		source, ok := hitMap["_source"]
-		if ok {
-			sourceBytes, _ := json.Marshal(source)
-			result.hits = append(result.hits, SearchHit{source: sourceBytes})
+		var searchHit SearchHit
+		if source, ok := hitMap["_source"]; ok {
+			searchHit.source, _ = json.Marshal(source)
+		}
+		if fields, ok := hitMap["fields"]; ok {
+			searchHit.fields, _ = json.Marshal(fields)
 		}
+		// hits without any of the requested fields are kept like in v7
+		searchHit.id, _ = hitMap["_id"].(string)
+		searchHit.index, _ = hitMap["_index"].(string)
+		searchHit.routing, _ = hitMap["_routing"].(string)
+		result.hits = append(result.hits, searchHit)
 
 		if s.scrollID == "" {
 			if scrollID, ok := resp["_scroll_id"].(string); ok {
@@ -209,8 +328,41 @@ func (s *ScrollService) Clear(ctx context.Context) error {
 	return nil
 }
 
-func (s *ScrollService) FetchSourceContext(includeFields []string) *ScrollService {
+func (s *ScrollService) FetchSourceContext(includeFields, excludeFields []string) *ScrollService {
 	s.includeFields = includeFields
+	s.excludeFields = excludeFields
+	return s
+}
+
+// FetchFields loads the given fields from the fields API, docvalue_fields or stored_fields
+// instead of _source. Use "*" to get all fields.
+func (s *ScrollService) FetchFields(mode string, fields []string) *ScrollService {
+	s.fetchMode = mode
+	if mode == elastic.FetchModeFields {
+		s.fields = append(s.fields, fields...)
+	} else {
+		s.fetchFields = fields
+	}
+	return s
+}
+
+// Fields requests additional values from the fields API next to _source.
+func (s *ScrollService) Fields(fields []string) *ScrollService {
+	s.fields = append(s.fields, fields...)
+	return s
+}
+
+func (s *ScrollService) RuntimeMappings(runtimeMappings map[string]interface{}) *ScrollService {
+	s.runtime = runtimeMappings
+	return s
+}
+
+func (s *ScrollService) Sort(field string, ascending bool) *ScrollService {
+	order := "asc"
+	if !ascending {
+		order = "desc"
+	}
+	s.sort = append(s.sort, map[string]interface{}{field: map[string]interface{}{"order": order}})
 	return s
 }
 
@@ -226,6 +378,22 @@ func (h *SearchHit) GetSource() []byte {
 	return h.source
 }
 
+func (h *SearchHit) GetFields() []byte {
+	return h.fields
+}
+
+func (h *SearchHit) GetID() string {
+	return h.id
+}
+
+func (h *SearchHit) GetIndex() string {
+	return h.index
+}
+
+func (h *SearchHit) GetRouting() string {
+	return h.routing
+}
+
 type BoolQuery struct {
 	builder *QueryBuilder
 }
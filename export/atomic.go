@@ -0,0 +1,96 @@
+package export
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// partialSuffix is added to the file name of kept partial output files.
+const partialSuffix = ".partial"
+
+// atomicFile writes to a hidden temporary file next to path that replaces path on Close, after it was
+// synced to disk, so downstream jobs never see a truncated file. After abort the temporary file is
+// removed on Close, or kept as path.partial.
+type atomicFile struct {
+	*os.File
+	path        string
+	keepPartial bool
+	aborted     bool
+}
+
+func createAtomic(path string, keepPartial bool) (*atomicFile, error) {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	// CreateTemp only allows the owner to read the file, unlike os.Create
+	if err := file.Chmod(0o644); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	return &atomicFile{File: file, path: path, keepPartial: keepPartial}, nil
+}
+
+// createAtomicCopy creates the temporary file of path with a copy of the existing file at path, or an
+// empty one if it does not exist yet. It is used for databases that are updated by their driver.
+func createAtomicCopy(path string, keepPartial bool) (*atomicFile, error) {
+	file, err := createAtomic(path, keepPartial)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return file, nil
+	}
+	if err == nil {
+		_, err = io.Copy(file, existing)
+		existing.Close()
+	}
+	if err != nil {
+		// nothing of a failed copy is kept
+		file.keepPartial = false
+		file.abort()
+		file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (f *atomicFile) abort() {
+	f.aborted = true
+}
+
+// Close syncs and renames the temporary file to its final path, or discards it after abort.
+func (f *atomicFile) Close() error {
+	if !f.aborted {
+		err := f.Sync()
+		if cerr := f.File.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			return os.Rename(f.Name(), f.path)
+		}
+		f.discard()
+		return err
+	}
+
+	err := f.File.Close()
+	if derr := f.discard(); err == nil {
+		err = derr
+	}
+	return err
+}
+
+// discard removes the closed temporary file or keeps it as partial file.
+func (f *atomicFile) discard() error {
+	if f.keepPartial {
+		return os.Rename(f.Name(), f.path+partialSuffix)
+	}
+	return os.Remove(f.Name())
+}
@@ -0,0 +1,119 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pteich/elastic-query-export/flags"
+)
+
+func Test_atomicOutput(t *testing.T) {
+	tests := []struct {
+		name        string
+		abort       bool
+		keepPartial bool
+		want        string
+	}{
+		{"completed", false, false, "output.csv"},
+		{"aborted", true, false, ""},
+		{"aborted with keep partial", true, true, "output.csv.partial"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			out, err := openOutput(filepath.Join(dir, "output.csv"), flags.CompressNone, 0, nil, tt.keepPartial)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := out.Write([]byte("a,b\n")); err != nil {
+				t.Fatal(err)
+			}
+
+			// nothing is visible under the final name before the output is closed
+			if _, err := os.Stat(filepath.Join(dir, "output.csv")); !os.IsNotExist(err) {
+				t.Errorf("output file exists before close")
+			}
+
+			if tt.abort {
+				out.Abort()
+			}
+			if err := out.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+
+			switch {
+			case tt.want == "" && len(names) != 0:
+				t.Errorf("expected no files, got %v", names)
+			case tt.want != "" && (len(names) != 1 || names[0] != tt.want):
+				t.Errorf("expected %s, got %v", tt.want, names)
+			}
+		})
+	}
+}
+
+func Test_createAtomicCopy(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "output.db")
+
+	// a new file starts empty
+	file, err := createAtomicCopy(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString("first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// an existing file is copied and stays unchanged until the copy replaces it
+	file, err = createAtomicCopy(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(" second"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "first" {
+		t.Errorf("existing file changed before close: %q", data)
+	}
+	file.abort()
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "first" {
+		t.Errorf("aborted copy replaced the file: %q", data)
+	}
+
+	file, err = createAtomicCopy(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(" second"); err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "first second" {
+		t.Errorf("unexpected content %q", data)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("expected only the output file, got %d files", len(entries))
+	}
+}
@@ -0,0 +1,45 @@
+package export
+
+import (
+	"fmt"
+	"os"
+
+	"filippo.io/age"
+
+	"github.com/pteich/elastic-query-export/flags"
+)
+
+// encrypted reports whether the output is encrypted with age.
+func encrypted(conf *flags.Flags) bool {
+	return conf.EncryptTo != "" || conf.EncryptToFile != ""
+}
+
+// encryptionRecipients returns the age recipients of --encrypt-to and --encrypt-to-file, or nil if
+// the output is not encrypted.
+func encryptionRecipients(conf *flags.Flags) ([]age.Recipient, error) {
+	var recipients []age.Recipient
+
+	for _, value := range splitList(conf.EncryptTo) {
+		recipient, err := age.ParseX25519Recipient(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %s - %w", value, err)
+		}
+		recipients = append(recipients, recipient)
+	}
+
+	if conf.EncryptToFile != "" {
+		file, err := os.Open(conf.EncryptToFile)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		fileRecipients, err := age.ParseRecipients(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading recipients from %s - %w", conf.EncryptToFile, err)
+		}
+		recipients = append(recipients, fileRecipients...)
+	}
+
+	return recipients, nil
+}
@@ -0,0 +1,79 @@
+package export
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/klauspost/pgzip"
+
+	"github.com/pteich/elastic-query-export/flags"
+)
+
+func Test_encryptedOutput(t *testing.T) {
+	first, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	recipientsFile := filepath.Join(dir, "recipients.txt")
+	if err := os.WriteFile(recipientsFile, []byte("# backup key\n"+second.Recipient().String()+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	recipients, err := encryptionRecipients(&flags.Flags{EncryptTo: first.Recipient().String(), EncryptToFile: recipientsFile})
+	if err != nil {
+		t.Fatalf("encryptionRecipients() error = %v", err)
+	}
+	if len(recipients) != 2 {
+		t.Fatalf("got %d recipients, want 2", len(recipients))
+	}
+
+	if _, err := encryptionRecipients(&flags.Flags{EncryptTo: "age1invalid"}); err == nil {
+		t.Error("expected error for invalid recipient")
+	}
+
+	path := filepath.Join(dir, "output.csv.gz.age")
+	out, err := openOutput(path, flags.CompressGzip, 0, recipients, false)
+	if err != nil {
+		t.Fatalf("openOutput() error = %v", err)
+	}
+	if _, err := io.WriteString(out, "hello world\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// every recipient can decrypt the output
+	for _, identity := range []*age.X25519Identity{first, second} {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		decrypted, err := age.Decrypt(file, identity)
+		if err != nil {
+			t.Fatalf("Decrypt() error = %v", err)
+		}
+		r, err := pgzip.NewReader(decrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "hello world\n" {
+			t.Errorf("read %q", data)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package export
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	elasticv7import "github.com/olivere/elastic/v7"
+	elasticsearch "github.com/pteich/elastic-query-export/elastic"
+)
+
+// The classes of errors returned by Run, test for them with errors.Is.
+var (
+	ErrConnection = errors.New("connection error")
+	ErrAuth       = errors.New("authentication error")
+	ErrQuery      = errors.New("query error")
+	ErrPartial    = errors.New("partial export")
+	ErrWrite      = errors.New("write error")
+)
+
+// errHook marks errors of hooks, which are returned as they are.
+var errHook = errors.New("hook failed")
+
+// errClientCertificate marks errors loading the client certificate, they are authentication errors.
+var errClientCertificate = errors.New("loading client certificate")
+
+// Result describes a finished export.
+type Result struct {
+	// Total is the number of documents matching the query
+	Total int64
+	// Read is the number of documents read from the cluster
+	Read int64
+	// Written is the number of documents written to the output or sent to the sink
+	Written int64
+	// Skipped is the number of documents that could not be written, like hits with invalid JSON
+	Skipped int64
+	// Warnings are the messages of the first skipped documents
+	Warnings []string
+}
+
+// elasticError classifies an error of a request to the cluster by its status. 401 and 403 are
+// authentication errors like invalid client certificates and failed TLS handshakes, other 4xx responses
+// query errors and everything else connection errors.
+func elasticError(action string, err error) error {
+	var kind error
+	switch status := elasticStatus(err); {
+	case status == http.StatusUnauthorized, status == http.StatusForbidden, tlsError(err):
+		kind = ErrAuth
+	case status >= 400 && status < 500:
+		kind = ErrQuery
+	default:
+		kind = ErrConnection
+	}
+	return fmt.Errorf("%w: %s - %w", kind, action, err)
+}
+
+// elasticStatus returns the HTTP status of an error response of any client version, or 0.
+func elasticStatus(err error) int {
+	var statusErr *elasticsearch.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Status
+	}
+	var v7Err *elasticv7import.Error
+	if errors.As(err, &v7Err) {
+		return v7Err.Status
+	}
+	return 0
+}
+
+// tlsError reports whether err is caused by the client certificate, a server certificate that can not
+// be verified or a TLS handshake the cluster rejected.
+func tlsError(err error) bool {
+	var verificationErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	switch {
+	case errors.Is(err, errClientCertificate),
+		errors.As(err, &verificationErr),
+		errors.As(err, &authorityErr),
+		errors.As(err, &hostnameErr),
+		errors.As(err, &invalidErr):
+		return true
+	}
+	// alerts sent by the server have no exported type
+	return strings.Contains(err.Error(), "remote error: tls: ")
+}
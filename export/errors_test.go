@@ -0,0 +1,63 @@
+package export
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+
+	elasticv7import "github.com/olivere/elastic/v7"
+	elasticsearch "github.com/pteich/elastic-query-export/elastic"
+	"github.com/pteich/elastic-query-export/flags"
+)
+
+func Test_elasticError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"unauthorized", &elasticsearch.StatusError{Status: 401, Message: "[401 Unauthorized]"}, ErrAuth},
+		{"forbidden", &elasticsearch.StatusError{Status: 403, Message: "[403 Forbidden]"}, ErrAuth},
+		{"unauthorized v7", &elasticv7import.Error{Status: 401}, ErrAuth},
+		{"forbidden v7", &elasticv7import.Error{Status: 403}, ErrAuth},
+		{"wrapped v7", fmt.Errorf("reading mapping - %w", &elasticv7import.Error{Status: 403}), ErrAuth},
+		{"client certificate", fmt.Errorf("%w - %w", errClientCertificate, errors.New("tls: failed to find any PEM data")), ErrAuth},
+		{"unknown authority", &url.Error{Op: "Get", URL: "https://es:9200", Err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}}, ErrAuth},
+		{"hostname", &url.Error{Op: "Get", URL: "https://es:9200", Err: x509.HostnameError{Host: "es"}}, ErrAuth},
+		{"rejected client certificate", &net.OpError{Op: "remote error", Err: errors.New("remote error: tls: certificate required")}, ErrAuth},
+		{"bad query", &elasticsearch.StatusError{Status: 400, Message: "[400 Bad Request] parsing_exception"}, ErrQuery},
+		{"missing index v7", &elasticv7import.Error{Status: 404}, ErrQuery},
+		{"unavailable", &elasticsearch.StatusError{Status: 503, Message: "[503 Service Unavailable]"}, ErrConnection},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, ErrConnection},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := elasticError("counting documents", tt.err)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("elasticError() = %v, want %v", err, tt.want)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("elasticError() does not wrap %v", tt.err)
+			}
+		})
+	}
+}
+
+func Test_newHTTPClient(t *testing.T) {
+	conf := flags.Defaults()
+	conf.ElasticClientCrt = "missing.crt"
+	conf.ElasticClientKey = "missing.key"
+
+	_, err := newHTTPClient(&conf)
+	if err == nil {
+		t.Fatal("newHTTPClient() expected error")
+	}
+	if err := elasticError("connecting to ElasticSearch", err); !errors.Is(err, ErrAuth) {
+		t.Errorf("elasticError() = %v, want %v", err, ErrAuth)
+	}
+}
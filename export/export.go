@@ -3,12 +3,15 @@ package export
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	elasticv7import "github.com/olivere/elastic/v7"
@@ -32,7 +35,7 @@ type elasticClient struct {
 	client  any
 }
 
-func (e *elasticClient) Count(ctx context.Context, index string, query any) (int64, error) {
+func (e *elasticClient) Count(ctx context.Context, index string, query any, runtimeMappings map[string]interface{}) (int64, error) {
 	switch e.version {
 	case 7:
 		client := e.client.(*elasticv7.Client)
@@ -40,21 +43,21 @@ func (e *elasticClient) Count(ctx context.Context, index string, query any) (int
 		if !ok {
 			return 0, errors.New("invalid query type for v7")
 		}
-		return client.Count(ctx, index, q)
+		return client.Count(ctx, index, q, runtimeMappings)
 	case 8:
 		client := e.client.(*elasticv8.Client)
 		q, ok := query.(elasticsearch.Query)
 		if !ok {
 			return 0, errors.New("invalid query type for v8")
 		}
-		return client.Count(ctx, index, q)
+		return client.Count(ctx, index, q, runtimeMappings)
 	case 9:
 		client := e.client.(*elasticv9.Client)
 		q, ok := query.(elasticsearch.Query)
 		if !ok {
 			return 0, errors.New("invalid query type for v9")
 		}
-		return client.Count(ctx, index, q)
+		return client.Count(ctx, index, q, runtimeMappings)
 	default:
 		return 0, errors.New("unsupported version")
 	}
@@ -88,6 +91,22 @@ func (e *elasticClient) Scroll(index string, size int, query any) any {
 	}
 }
 
+func (e *elasticClient) Mapping(ctx context.Context, index string) ([]elasticsearch.Field, error) {
+	switch e.version {
+	case 7:
+		client := e.client.(*elasticv7.Client)
+		return client.Mapping(ctx, index)
+	case 8:
+		client := e.client.(*elasticv8.Client)
+		return client.Mapping(ctx, index)
+	case 9:
+		client := e.client.(*elasticv9.Client)
+		return client.Mapping(ctx, index)
+	default:
+		return nil, errors.New("unsupported version")
+	}
+}
+
 func (e *elasticClient) Stop() {
 	switch e.version {
 	case 7:
@@ -146,43 +165,285 @@ func scrollServiceClear(ctx context.Context, version int, scrollService any) err
 	}
 }
 
-func scrollServiceFetchSourceContext(version int, scrollService any, includeFields []string) any {
+func scrollServiceFetchSourceContext(version int, scrollService any, includeFields, excludeFields []string) any {
 	switch version {
 	case 7:
 		scroll := scrollService.(*elasticv7.ScrollService)
-		return scroll.FetchSourceContext(includeFields)
+		return scroll.FetchSourceContext(includeFields, excludeFields)
 	case 8:
 		scroll := scrollService.(*elasticv8.ScrollService)
-		return scroll.FetchSourceContext(includeFields)
+		return scroll.FetchSourceContext(includeFields, excludeFields)
 	case 9:
 		scroll := scrollService.(*elasticv9.ScrollService)
-		return scroll.FetchSourceContext(includeFields)
+		return scroll.FetchSourceContext(includeFields, excludeFields)
 	default:
 		return nil
 	}
 }
 
-func Run(ctx context.Context, conf *flags.Flags) {
-	client, query, err := createClientAndQuery(conf)
+func scrollServiceFetchFields(version int, scrollService any, mode string, fields []string) any {
+	switch version {
+	case 7:
+		scroll := scrollService.(*elasticv7.ScrollService)
+		return scroll.FetchFields(mode, fields)
+	case 8:
+		scroll := scrollService.(*elasticv8.ScrollService)
+		return scroll.FetchFields(mode, fields)
+	case 9:
+		scroll := scrollService.(*elasticv9.ScrollService)
+		return scroll.FetchFields(mode, fields)
+	default:
+		return nil
+	}
+}
+
+func scrollServiceFields(version int, scrollService any, fields []string) any {
+	switch version {
+	case 7:
+		scroll := scrollService.(*elasticv7.ScrollService)
+		return scroll.Fields(fields)
+	case 8:
+		scroll := scrollService.(*elasticv8.ScrollService)
+		return scroll.Fields(fields)
+	case 9:
+		scroll := scrollService.(*elasticv9.ScrollService)
+		return scroll.Fields(fields)
+	default:
+		return nil
+	}
+}
+
+func scrollServiceRuntimeMappings(version int, scrollService any, runtimeMappings map[string]interface{}) any {
+	switch version {
+	case 7:
+		scroll := scrollService.(*elasticv7.ScrollService)
+		return scroll.RuntimeMappings(runtimeMappings)
+	case 8:
+		scroll := scrollService.(*elasticv8.ScrollService)
+		return scroll.RuntimeMappings(runtimeMappings)
+	case 9:
+		scroll := scrollService.(*elasticv9.ScrollService)
+		return scroll.RuntimeMappings(runtimeMappings)
+	default:
+		return nil
+	}
+}
+
+func scrollServiceSort(version int, scrollService any, field string, ascending bool) any {
+	switch version {
+	case 7:
+		scroll := scrollService.(*elasticv7.ScrollService)
+		return scroll.Sort(field, ascending)
+	case 8:
+		scroll := scrollService.(*elasticv8.ScrollService)
+		return scroll.Sort(field, ascending)
+	case 9:
+		scroll := scrollService.(*elasticv9.ScrollService)
+		return scroll.Sort(field, ascending)
+	default:
+		return nil
+	}
+}
+
+func scrollServiceBody(version int, scrollService any) (map[string]interface{}, error) {
+	switch version {
+	case 7:
+		scroll := scrollService.(*elasticv7.ScrollService)
+		return scroll.Body()
+	case 8:
+		scroll := scrollService.(*elasticv8.ScrollService)
+		return scroll.Body(), nil
+	case 9:
+		scroll := scrollService.(*elasticv9.ScrollService)
+		return scroll.Body(), nil
+	default:
+		return nil, errors.New("unsupported version")
+	}
+}
+
+// Run exports the documents matching the query. The returned error is classified as ErrConnection,
+// ErrAuth, ErrQuery, ErrPartial or ErrWrite, errors of the configuration are not classified. The result
+// is returned for partial exports as well.
+func (e *Exporter) Run(ctx context.Context) (result *Result, err error) {
+	started := time.Now()
+
+	// the settings are completed for every run, like the placeholders of the output file name
+	settings := e.conf
+	conf := &settings
+
+	// the raw query is sent as it is, invalid JSON would only fail when the request is encoded
+	if conf.RAWQuery != "" && !json.Valid([]byte(conf.RAWQuery)) {
+		return nil, fmt.Errorf("%w: raw query is not valid JSON", ErrQuery)
+	}
+
+	client, query, err := createClientAndQuery(conf, e.logger)
 	if err != nil {
-		log.Fatalf("Error connecting to ElasticSearch: %s", err)
+		if errors.Is(err, errClientCertificate) {
+			return nil, elasticError("connecting to ElasticSearch", err)
+		}
+		// the client does not send requests yet, so all other errors are errors of the configuration
+		return nil, fmt.Errorf("creating ElasticSearch client - %w", err)
 	}
 	defer client.Stop()
 
-	if conf.Fieldlist != "" {
-		conf.Fields = strings.Split(conf.Fieldlist, ",")
+	var columns []formats.Column
+
+	if conf.FieldsFile != "" {
+		columns, err = formats.LoadColumns(conf.FieldsFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading fields file - %w", err)
+		}
+	} else if conf.Fieldlist != "" {
+		columns, err = formats.ParseColumns(conf.Fieldlist)
+		if err != nil {
+			return nil, fmt.Errorf("parsing fields - %w", err)
+		}
+	}
+
+	if len(columns) > 0 {
+		conf.Fields = formats.SourceFields(columns)
+	}
+
+	switch conf.FetchMode {
+	case "", elasticsearch.FetchModeSource, elasticsearch.FetchModeFields, elasticsearch.FetchModeDocvalues, elasticsearch.FetchModeStored:
+	default:
+		return nil, fmt.Errorf("unknown fetch mode %s", conf.FetchMode)
+	}
+
+	switch conf.ArrowFormat {
+	case "", flags.ArrowFormatFile, flags.ArrowFormatStream:
+	default:
+		return nil, fmt.Errorf("unknown arrow format %s", conf.ArrowFormat)
+	}
+
+	conf.ExcludeFields = splitList(conf.ExcludeFieldlist)
+
+	conf.Outfile, err = outfileName(conf, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("creating output file name - %w", err)
 	}
 
-	var outfile *os.File
+	splitBytes, err := parseByteSize(conf.SplitBytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing split size - %w", err)
+	}
+	// the Avro schema is always written to a single file
+	split := (conf.SplitRows > 0 || splitBytes > 0) && !(conf.OutFormat == flags.FormatAvro && conf.AvroSchema)
+
+	partitionKeys, err := formats.ParsePartitionKeys(conf.PartitionBy)
+	if err != nil {
+		return nil, fmt.Errorf("parsing partition keys - %w", err)
+	}
+	partitioned := len(partitionKeys) > 0
 
-	if conf.Outfile == "-" {
-		outfile = os.Stdout
+	codec := compression(conf)
+
+	recipients, err := encryptionRecipients(conf)
+	if err != nil {
+		return nil, fmt.Errorf("reading encryption recipients - %w", err)
+	}
+
+	var s3 *s3Output
+	if isS3(conf.Outfile) {
+		s3, err = newS3Output(conf)
+		if err != nil {
+			return nil, fmt.Errorf("creating S3 client - %w", err)
+		}
+	}
+
+	outputs := &outputRecorder{}
+
+	// open opens an output file, parts and partitions of S3 outputs are uploaded to S3 as well
+	open := func(path string) (*outputFile, error) {
+		var out *outputFile
+		var err error
+		if path == "-" {
+			out, err = newOutputFile(e.writer, nil, nil, codec, conf.CompressLevel, recipients)
+		} else if isS3(path) {
+			out, err = s3.open(ctx, path, codec, conf.CompressLevel, recipients)
+		} else {
+			out, err = openOutput(path, codec, conf.CompressLevel, recipients, conf.KeepPartial)
+		}
+		if err != nil {
+			return nil, err
+		}
+		outputs.add(path, out)
+		return out, nil
+	}
+
+	mode, err := progressMode(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	switch conf.Sink {
+	case "", flags.SinkFile, flags.SinkElasticsearch, flags.SinkHTTP:
+	default:
+		return nil, fmt.Errorf("unknown sink %s", conf.Sink)
+	}
+
+	if conf.OutFormat == flags.FormatAvro && conf.AvroSchema {
+		// the schema is written to the output file only
+		if conf.Sink != "" && conf.Sink != flags.SinkFile {
+			return nil, fmt.Errorf("the Avro schema can not be written to the %s sink", conf.Sink)
+		}
+		if partitioned {
+			return nil, errors.New("the Avro schema can not be partitioned")
+		}
+	}
+
+	var outfile *outputFile
+
+	if conf.Sink == flags.SinkElasticsearch {
+		if conf.TargetURL == "" {
+			return nil, errors.New("the elasticsearch sink needs a --target-url")
+		}
+	} else if conf.Sink == flags.SinkHTTP {
+		if conf.SinkURL == "" {
+			return nil, errors.New("the http sink needs a --sink-url")
+		}
+	} else if conf.OutFormat == flags.FormatSQLite {
+		// the database file is opened by the SQLite driver itself, see createAtomicCopy
+		if conf.Outfile == "-" {
+			return nil, errors.New("SQLite output can not be written to stdout")
+		}
+		if codec != flags.CompressNone {
+			return nil, errors.New("SQLite output can not be compressed")
+		}
+		if len(recipients) > 0 {
+			return nil, errors.New("SQLite output can not be encrypted")
+		}
+		if s3 != nil {
+			return nil, errors.New("SQLite output can not be uploaded to S3")
+		}
+		if split {
+			return nil, errors.New("SQLite output can not be split")
+		}
+		if partitioned {
+			return nil, errors.New("SQLite output can not be partitioned")
+		}
+	} else if partitioned {
+		// every partition file is opened by the partitioner
+		if conf.Outfile == "-" {
+			return nil, errors.New("output written to stdout can not be partitioned")
+		}
+	} else if split {
+		// every part is opened by the splitter
+		if conf.Outfile == "-" {
+			return nil, errors.New("output written to stdout can not be split")
+		}
 	} else {
-		outfile, err = os.Create(conf.Outfile)
+		outfile, err = open(conf.Outfile)
 		if err != nil {
-			log.Fatalf("Error creating output file - %s", err)
+			return nil, fmt.Errorf("%w: creating output file - %w", ErrWrite, err)
 		}
-		defer outfile.Close()
+		// the output of a failed export is discarded, see atomicFile
+		defer func() {
+			if err != nil {
+				outfile.Abort()
+			}
+			outfile.Close()
+		}()
 	}
 
 	var rangeQuery any
@@ -213,31 +474,157 @@ func Run(ctx context.Context, conf *flags.Flags) {
 
 	query = buildFinalQuery(client.version, esQuery)
 
-	total, err := client.Count(ctx, conf.Index, query)
+	runtimeMappings, err := runtimeFields(conf.RuntimeField, conf.RuntimeFieldFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading runtime fields - %w", err)
+	}
+
+	total, err := client.Count(ctx, conf.Index, query, runtimeMappings)
 	if err != nil {
-		log.Fatalf("Error counting ElasticSearch documents: %s", err)
+		return nil, elasticError("counting documents", err)
+	}
+
+	fetchFromFields := conf.FetchMode != "" && conf.FetchMode != elasticsearch.FetchModeSource
+	// runtime fields are never part of _source, so their values are merged in from the fields API
+	mergeFields := !fetchFromFields && runtimeMappings != nil
+
+	scroll := client.Scroll(conf.Index, conf.ScrollSize, query)
+
+	if runtimeMappings != nil {
+		scroll = scrollServiceRuntimeMappings(client.version, scroll, runtimeMappings)
+	}
+
+	for _, sort := range parseSort(conf.Sort) {
+		scroll = scrollServiceSort(client.version, scroll, sort.field, sort.ascending)
+	}
+
+	if mergeFields {
+		scroll = scrollServiceFields(client.version, scroll, runtimeFieldNames(runtimeMappings))
+	}
+
+	if fetchFromFields {
+		fields := conf.Fields
+		if fields == nil {
+			fields = []string{"*"}
+		}
+		scroll = scrollServiceFetchFields(client.version, scroll, conf.FetchMode, fields)
+	} else if conf.Fields != nil || conf.ExcludeFields != nil {
+		scroll = scrollServiceFetchSourceContext(client.version, scroll, conf.Fields, conf.ExcludeFields)
+	}
+
+	var exportManifest *manifest
+	if conf.Manifest != "" {
+		body, err := scrollServiceBody(client.version, scroll)
+		if err != nil {
+			return nil, fmt.Errorf("creating manifest - %w", err)
+		}
+		// the size is sent as parameter of the search request
+		body["size"] = conf.ScrollSize
+
+		exportManifest, err = newManifest(ctx, conf, body)
+		if err != nil {
+			return nil, fmt.Errorf("creating manifest - %w", err)
+		}
+		exportManifest.Total = total
+		exportManifest.Started = started
+	}
+	var mapping []elasticsearch.Field
+	switch conf.OutFormat {
+	case flags.FormatParquet, flags.FormatArrow, flags.FormatAvro, flags.FormatXLSX, flags.FormatSQLite:
+		mapping, err = loadMapping(ctx, client, conf, runtimeMappings)
+		if err != nil {
+			return nil, elasticError("reading index mapping", err)
+		}
+	}
+
+	// wildcard columns are expanded from the mapping, so fields missing in the first documents get a column as well
+	if formats.HasWildcards(columns) {
+		fields := mapping
+		if fields == nil {
+			fields, err = loadMapping(ctx, client, conf, runtimeMappings)
+			if err != nil {
+				return nil, elasticError("reading index mapping", err)
+			}
+		}
+		var names []string
+		for _, field := range elasticsearch.LeafFields(fields) {
+			names = append(names, field.Name)
+		}
+		columns = formats.ExpandColumns(columns, names)
+	}
+
+	if conf.OutFormat == flags.FormatAvro && conf.AvroSchema {
+		schema, err := formats.AvroSchema(mapping)
+		if err != nil {
+			return nil, fmt.Errorf("generating Avro schema - %w", err)
+		}
+		if _, err := fmt.Fprintln(outfile, schema); err != nil {
+			return nil, fmt.Errorf("%w: writing Avro schema - %w", ErrWrite, err)
+		}
+		return &Result{Total: total}, nil
 	}
-	bar := pb.StartNew(int(total))
+
+	progress := newProgress(os.Stderr, mode, total, outputs)
+	progress.callback = e.onProgress
+	progress.summaryOnSignal = e.summaryOnSignal
+	bar := progress.bar
+	// stops the bar of failed exports
+	defer bar.Finish()
+
+	warnings := &formats.Warnings{Logger: e.logger}
+	var read atomic.Int64
+
+	// a failed scroll cancels the export, so formatters and outputs can tell it from a completed one
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	progressCtx, stopProgress := context.WithCancel(ctx)
+	defer stopProgress()
+	interval := e.progressInterval
+	if interval <= 0 {
+		interval = time.Duration(conf.ProgressInterval) * time.Second
+	}
+	go progress.run(progressCtx, interval)
 
 	hits := make(chan elasticsearch.SearchHit)
 
 	go func() {
 		defer close(hits)
-
-		scroll := client.Scroll(conf.Index, conf.ScrollSize, query)
 		defer scrollServiceClear(ctx, client.version, scroll)
 
-		if conf.Fields != nil {
-			scroll = scrollServiceFetchSourceContext(client.version, scroll, conf.Fields)
+		send := func(hit elasticsearch.SearchHit) bool {
+			read.Add(1)
+			if fetchFromFields || mergeFields {
+				hit = newFieldsSearchHit(hit, mergeFields, warnings)
+			}
+			for _, hook := range e.hooks {
+				var err error
+				if hit, err = hook.Hit(ctx, hit); err != nil {
+					cancel(fmt.Errorf("%w - %w", errHook, err))
+					return false
+				}
+				if hit == nil {
+					// the hook dropped the hit
+					return true
+				}
+			}
+			select {
+			case hits <- hit:
+				return true
+			case <-ctx.Done():
+				return false
+			}
 		}
 
 		for {
+			pageStarted := time.Now()
 			hitsData, scrollTotal, err := scrollServiceDo(ctx, client.version, scroll)
+			progress.pageLatency.Store(int64(time.Since(pageStarted)))
 			if err != nil {
 				if errors.Is(err, io.EOF) {
 					return
 				}
-				log.Println(err)
+				cancel(elasticError("scrolling", err))
 				return
 			}
 
@@ -245,27 +632,21 @@ func Run(ctx context.Context, conf *flags.Flags) {
 			case 7:
 				v7Hits := hitsData.([]*elasticv7.SearchHit)
 				for _, hit := range v7Hits {
-					select {
-					case hits <- &v7SearchHit{hit: hit}:
-					case <-ctx.Done():
+					if !send(&v7SearchHit{hit: hit}) {
 						return
 					}
 				}
 			case 8:
 				v8Hits := hitsData.([]elasticv8.SearchHit)
 				for _, hit := range v8Hits {
-					select {
-					case hits <- &v8SearchHit{hit: &hit}:
-					case <-ctx.Done():
+					if !send(&v8SearchHit{hit: &hit}) {
 						return
 					}
 				}
 			case 9:
 				v9Hits := hitsData.([]elasticv9.SearchHit)
 				for _, hit := range v9Hits {
-					select {
-					case hits <- &v9SearchHit{hit: &hit}:
-					case <-ctx.Done():
+					if !send(&v9SearchHit{hit: &hit}) {
 						return
 					}
 				}
@@ -278,35 +659,348 @@ func Run(ctx context.Context, conf *flags.Flags) {
 	}()
 
 	var output Formatter
+	var parts interface{ writtenParts() []splitPart }
+	var database *atomicFile
+
+	switch {
+	case conf.Sink == flags.SinkElasticsearch:
+		sink, err := newBulkSink(conf, bar, &progress.retries, warnings)
+		if err != nil {
+			return nil, fmt.Errorf("creating elasticsearch sink - %w", err)
+		}
+		if sink.FailureFile != nil {
+			defer sink.FailureFile.Close()
+		}
+		output = sink
+	case conf.Sink == flags.SinkHTTP:
+		sink, err := newHTTPSink(conf, bar, &progress.retries, warnings, columns)
+		if err != nil {
+			return nil, fmt.Errorf("creating http sink - %w", err)
+		}
+		output = sink
+	case partitioned:
+		root, pattern := splitOutput(conf.Outfile)
+		partitions := &partitioner{
+			root:    root,
+			pattern: pattern,
+			keys:    partitionKeys,
+			maxOpen: conf.MaxOpenFiles,
+			rows:    conf.SplitRows,
+			bytes:   splitBytes,
+			open:    open,
+			formatter: func(w io.Writer, path string) (Formatter, error) {
+				return newFormatter(conf, w, path, open, bar, warnings, columns, mapping)
+			},
+			warnings: warnings,
+		}
+		parts, output = partitions, partitions
+	case split:
+		splits := &splitter{
+			pattern: conf.Outfile,
+			rows:    conf.SplitRows,
+			bytes:   splitBytes,
+			open:    open,
+			formatter: func(w io.Writer, path string) (Formatter, error) {
+				return newFormatter(conf, w, path, open, bar, warnings, columns, mapping)
+			},
+		}
+		parts, output = splits, splits
+	case conf.OutFormat == flags.FormatSQLite:
+		// the driver writes to a copy of the database that replaces it when the export is complete
+		database, err = createAtomicCopy(conf.Outfile, conf.KeepPartial)
+		if err != nil {
+			return nil, fmt.Errorf("%w: creating output file - %w", ErrWrite, err)
+		}
+		output, err = newFormatter(conf, nil, database.Name(), open, bar, warnings, columns, mapping)
+		if err != nil {
+			database.abort()
+			database.Close()
+			return nil, fmt.Errorf("creating output - %w", err)
+		}
+	default:
+		output, err = newFormatter(conf, outfile, conf.Outfile, open, bar, warnings, columns, mapping)
+		if err != nil {
+			return nil, fmt.Errorf("creating output - %w", err)
+		}
+	}
+
+	err = output.Run(ctx, hits)
+	if cause := context.Cause(ctx); cause != nil {
+		// the hits channel is closed on errors and interrupts as well
+		err = cause
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrConnection), errors.Is(err, ErrAuth), errors.Is(err, ErrQuery), errors.Is(err, errHook):
+		// the scroll or a hook failed
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		err = fmt.Errorf("%w: export interrupted - %w", ErrPartial, err)
+	default:
+		err = fmt.Errorf("%w: %w", ErrWrite, err)
+	}
+
+	if outfile != nil {
+		if err != nil {
+			outfile.Abort()
+		}
+		if cerr := outfile.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("%w: closing output - %w", ErrWrite, cerr)
+		}
+	}
+	if database != nil {
+		if err != nil {
+			database.abort()
+		}
+		if cerr := database.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("%w: closing output - %w", ErrWrite, cerr)
+		}
+	}
+
+	stopProgress()
+	progress.finish()
+
+	if parts != nil {
+		printParts(e.logger.Writer(), parts.writtenParts())
+	}
+
+	result = &Result{
+		Total:    total,
+		Read:     read.Load(),
+		Written:  bar.Current(),
+		Skipped:  warnings.Skipped(),
+		Warnings: warnings.Messages(),
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if exportManifest != nil {
+		exportManifest.Written = result.Written
+		exportManifest.Files = outputs.files()
+		if conf.OutFormat == flags.FormatSQLite {
+			file, err := hashFile(conf.Outfile)
+			if err != nil {
+				return result, fmt.Errorf("%w: hashing output - %w", ErrWrite, err)
+			}
+			exportManifest.Files = append(exportManifest.Files, file)
+		}
+		exportManifest.Finished = time.Now()
+
+		if err := exportManifest.write(conf.Manifest); err != nil {
+			return result, fmt.Errorf("%w: writing manifest - %w", ErrWrite, err)
+		}
+	}
+
+	if result.Skipped > 0 {
+		return result, fmt.Errorf("%w: %d of %d documents were skipped", ErrPartial, result.Skipped, result.Read)
+	}
+
+	return result, nil
+}
+
+// newFormatter creates the formatter of the output format that writes to w. The path and open are
+// needed by formats that create files themselves.
+func newFormatter(conf *flags.Flags, w io.Writer, path string, open func(path string) (*outputFile, error), bar *pb.ProgressBar, warnings *formats.Warnings, columns []formats.Column, mapping []elasticsearch.Field) (Formatter, error) {
 	switch conf.OutFormat {
 	case flags.FormatJSON:
-		output = formats.JSON{
-			Outfile:    outfile,
+		return formats.JSON{
+			Outfile:    w,
 			ProgessBar: bar,
-		}
+			Warnings:   warnings,
+			Style:      conf.JSONStyle,
+			Columns:    columns,
+		}, nil
 	case flags.FormatRAW:
-		output = formats.Raw{
-			Outfile:    outfile,
+		return formats.Raw{
+			Outfile:    w,
 			ProgessBar: bar,
+			Warnings:   warnings,
+		}, nil
+	case flags.FormatParquet:
+		return formats.Parquet{
+			Outfile:      w,
+			ProgessBar:   bar,
+			Warnings:     warnings,
+			Mapping:      mapping,
+			RowGroupSize: conf.RowGroupSize,
+			Compression:  conf.ParquetCodec,
+		}, nil
+	case flags.FormatArrow:
+		return formats.Arrow{
+			Outfile:    w,
+			ProgessBar: bar,
+			Warnings:   warnings,
+			Mapping:    mapping,
+			BatchSize:  conf.ScrollSize,
+			Stream:     conf.ArrowFormat == flags.ArrowFormatStream,
+		}, nil
+	case flags.FormatAvro:
+		return formats.Avro{
+			Outfile:    w,
+			ProgessBar: bar,
+			Warnings:   warnings,
+			Mapping:    mapping,
+			Codec:      conf.AvroCodec,
+		}, nil
+	case flags.FormatXLSX:
+		return formats.XLSX{
+			Outfile:    w,
+			ProgessBar: bar,
+			Warnings:   warnings,
+			Mapping:    mapping,
+		}, nil
+	case flags.FormatSQLite:
+		return formats.SQLite{
+			Path:       path,
+			ProgessBar: bar,
+			Warnings:   warnings,
+			Mapping:    mapping,
+			Table:      formats.SQLiteTable(conf.Index),
+			Indexes:    splitList(conf.SQLiteIndex),
+			Append:     conf.Append,
+			BatchSize:  conf.ScrollSize,
+		}, nil
+	case flags.FormatBulk:
+		maxBytes, err := parseByteSize(conf.BulkMaxSize)
+		if err != nil {
+			return nil, err
 		}
+		if maxBytes > 0 && path == "-" {
+			return nil, errors.New("bulk output written to stdout can not be split")
+		}
+		return formats.Bulk{
+			Outfile:    w,
+			ProgessBar: bar,
+			Warnings:   warnings,
+			Path:       path,
+			Index:      conf.BulkIndex,
+			OpType:     conf.BulkOp,
+			DropID:     conf.BulkDropID,
+			MaxBytes:   maxBytes,
+			Create: func(path string) (io.WriteCloser, error) {
+				return open(path)
+			},
+		}, nil
+	case flags.FormatTemplate:
+		text, err := os.ReadFile(conf.TemplateFile)
+		if err != nil {
+			return nil, err
+		}
+		return formats.Template{
+			Outfile:    w,
+			ProgessBar: bar,
+			Warnings:   warnings,
+			Text:       string(text),
+			Header:     conf.TemplateHeader,
+			Footer:     conf.TemplateFooter,
+		}, nil
 	default:
-		output = formats.CSV{
-			Conf:       conf,
-			Outfile:    outfile,
+		return formats.CSV{
+			Columns:    columns,
+			Outfile:    w,
 			Workers:    workers,
 			ProgessBar: bar,
+			Warnings:   warnings,
+		}, nil
+	}
+}
+
+// newBulkSink creates the sink that writes to the target cluster with the same credentials and TLS settings
+// as the source cluster.
+func newBulkSink(conf *flags.Flags, bar *pb.ProgressBar, retries *atomic.Int64, warnings *formats.Warnings) (*formats.BulkSink, error) {
+	httpClient, err := newHTTPClient(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	var failureFile *os.File
+	if conf.FailureFile != "" {
+		failureFile, err = os.Create(conf.FailureFile)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	err = output.Run(ctx, hits)
+	return &formats.BulkSink{
+		Client:      httpClient,
+		URL:         conf.TargetURL,
+		User:        conf.ElasticUser,
+		Pass:        conf.ElasticPass,
+		ProgessBar:  bar,
+		Index:       conf.BulkIndex,
+		OpType:      conf.BulkOp,
+		DropID:      conf.BulkDropID,
+		BatchSize:   conf.TargetBatchSize,
+		Workers:     conf.TargetWorkers,
+		MaxRetries:  5,
+		FailureFile: failureFile,
+		Retries:     retries,
+		Warnings:    warnings,
+	}, nil
+}
+
+func newHTTPSink(conf *flags.Flags, bar *pb.ProgressBar, retries *atomic.Int64, warnings *formats.Warnings, columns []formats.Column) (*formats.HTTPSink, error) {
+	headers := http.Header{}
+	for _, header := range strings.Split(conf.SinkHeaders, ";") {
+		if strings.TrimSpace(header) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid header %q, use 'Name: value'", strings.TrimSpace(header))
+		}
+		headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	return &formats.HTTPSink{
+		Client:     &http.Client{Timeout: time.Minute},
+		URL:        conf.SinkURL,
+		Headers:    headers,
+		User:       conf.SinkUser,
+		Pass:       conf.SinkPass,
+		Token:      conf.SinkToken,
+		ProgessBar: bar,
+		Style:      conf.JSONStyle,
+		Columns:    columns,
+		BatchSize:  conf.TargetBatchSize,
+		Workers:    conf.TargetWorkers,
+		MaxRetries: 5,
+		Retries:    retries,
+		Warnings:   warnings,
+	}, nil
+}
+
+// splitList splits a comma separated list and drops empty entries.
+func splitList(list string) []string {
+	var values []string
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// loadMapping returns the fields of the index mapping including runtime fields, reduced to the
+// selected and not excluded fields.
+func loadMapping(ctx context.Context, client *elasticClient, conf *flags.Flags, runtimeMappings map[string]interface{}) ([]elasticsearch.Field, error) {
+	mapping, err := client.Mapping(ctx, conf.Index)
 	if err != nil {
-		log.Printf("Failed to write output: %s", err)
+		return nil, err
+	}
+
+	for _, name := range runtimeFieldNames(runtimeMappings) {
+		definition := runtimeMappings[name].(map[string]interface{})
+		mapping = elasticsearch.AddField(mapping, name, definition["type"].(string))
 	}
 
-	bar.Finish()
+	mapping = elasticsearch.FilterFields(mapping, conf.Fields)
+	mapping = elasticsearch.MarkArrays(mapping, splitList(conf.ArrayFields))
+	return elasticsearch.RemoveFields(mapping, conf.ExcludeFields), nil
 }
 
-func createClientAndQuery(conf *flags.Flags) (*elasticClient, any, error) {
+// newHTTPClient returns a HTTP client with the TLS settings of the given flags.
+func newHTTPClient(conf *flags.Flags) (*http.Client, error) {
 	tlsCfg := &tls.Config{
 		InsecureSkipVerify: !conf.ElasticVerifySSL,
 	}
@@ -314,7 +1008,7 @@ func createClientAndQuery(conf *flags.Flags) (*elasticClient, any, error) {
 	if conf.ElasticClientCrt != "" && conf.ElasticClientKey != "" {
 		cert, err := tls.LoadX509KeyPair(conf.ElasticClientCrt, conf.ElasticClientKey)
 		if err != nil {
-			return nil, nil, err
+			return nil, fmt.Errorf("%w - %w", errClientCertificate, err)
 		}
 		tlsCfg.Certificates = []tls.Certificate{cert}
 	}
@@ -322,9 +1016,16 @@ func createClientAndQuery(conf *flags.Flags) (*elasticClient, any, error) {
 	tr := &http.Transport{
 		TLSClientConfig: tlsCfg,
 	}
-	httpClient := &http.Client{Transport: tr}
+	return &http.Client{Transport: tr}, nil
+}
 
-	logger := elasticv7.GetDefaultLogger()
+func createClientAndQuery(conf *flags.Flags, errorLog *log.Logger) (*elasticClient, any, error) {
+	httpClient, err := newHTTPClient(conf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger := log.New(errorLog.Writer(), "ELASTIC ", log.LstdFlags)
 
 	switch conf.ElasticVersion {
 	case 7:
@@ -332,7 +1033,9 @@ func createClientAndQuery(conf *flags.Flags) (*elasticClient, any, error) {
 			elasticv7.SetHttpClient(httpClient),
 			elasticv7.SetURL(conf.ElasticURL),
 			elasticv7.SetSniff(false),
-			elasticv7.SetHealthcheckInterval(60 * time.Second),
+			// the startup healthcheck hides the status of rejected credentials and TLS errors, without it
+			// they are returned by the first request
+			elasticv7.SetHealthcheck(false),
 			elasticv7.SetErrorLog(logger),
 		}
 
@@ -519,6 +1222,22 @@ func (h *v7SearchHit) GetSource() []byte {
 	return h.hit.GetSource()
 }
 
+func (h *v7SearchHit) GetFields() []byte {
+	return h.hit.GetFields()
+}
+
+func (h *v7SearchHit) GetID() string {
+	return h.hit.GetID()
+}
+
+func (h *v7SearchHit) GetIndex() string {
+	return h.hit.GetIndex()
+}
+
+func (h *v7SearchHit) GetRouting() string {
+	return h.hit.GetRouting()
+}
+
 type v8SearchHit struct {
 	hit *elasticv8.SearchHit
 }
@@ -527,6 +1246,22 @@ func (h *v8SearchHit) GetSource() []byte {
 	return h.hit.GetSource()
 }
 
+func (h *v8SearchHit) GetFields() []byte {
+	return h.hit.GetFields()
+}
+
+func (h *v8SearchHit) GetID() string {
+	return h.hit.GetID()
+}
+
+func (h *v8SearchHit) GetIndex() string {
+	return h.hit.GetIndex()
+}
+
+func (h *v8SearchHit) GetRouting() string {
+	return h.hit.GetRouting()
+}
+
 type v9SearchHit struct {
 	hit *elasticv9.SearchHit
 }
@@ -534,3 +1269,57 @@ type v9SearchHit struct {
 func (h *v9SearchHit) GetSource() []byte {
 	return h.hit.GetSource()
 }
+
+func (h *v9SearchHit) GetFields() []byte {
+	return h.hit.GetFields()
+}
+
+func (h *v9SearchHit) GetID() string {
+	return h.hit.GetID()
+}
+
+func (h *v9SearchHit) GetIndex() string {
+	return h.hit.GetIndex()
+}
+
+func (h *v9SearchHit) GetRouting() string {
+	return h.hit.GetRouting()
+}
+
+// fieldsSearchHit serves the fields section of a hit as its source, so every formatter can work with
+// values from the fields API, docvalue_fields or stored_fields. Fields always come as arrays,
+// arrays with a single value are unwrapped. With withSource the fields are merged into the original source.
+type fieldsSearchHit struct {
+	elasticsearch.SearchHit
+	source []byte
+}
+
+func newFieldsSearchHit(hit elasticsearch.SearchHit, withSource bool, warnings *formats.Warnings) *fieldsSearchHit {
+	document := make(map[string]interface{})
+	if withSource && hit.GetSource() != nil {
+		if err := json.Unmarshal(hit.GetSource(), &document); err != nil {
+			return &fieldsSearchHit{SearchHit: hit, source: hit.GetSource()}
+		}
+	}
+
+	var fields map[string]interface{}
+	if hit.GetFields() != nil {
+		if err := json.Unmarshal(hit.GetFields(), &fields); err != nil {
+			warnings.Logf("Error unmarshal fields from ElasticSearch - %v", err)
+		}
+	}
+
+	for key, val := range fields {
+		if values, ok := val.([]interface{}); ok && len(values) == 1 {
+			val = values[0]
+		}
+		document[key] = val
+	}
+
+	source, _ := json.Marshal(document)
+	return &fieldsSearchHit{SearchHit: hit, source: source}
+}
+
+func (h *fieldsSearchHit) GetSource() []byte {
+	return h.source
+}
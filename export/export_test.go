@@ -110,7 +110,9 @@ func TestExportE2E(t *testing.T) {
 				Timefield:        "@timestamp",
 			}
 
-			Run(ctx, conf)
+			if _, err := Run(ctx, conf); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
 
 			// Verify output
 			verifyOutput(t, outFileName, 3)
@@ -174,3 +176,26 @@ func verifyOutput(t *testing.T, filename string, expectedLines int) {
 		t.Errorf("expected %d lines in output (including header), got %d", expectedLines+1, lines)
 	}
 }
+
+func Test_newFieldsSearchHit(t *testing.T) {
+	tests := []struct {
+		name       string
+		hit        testHit
+		withSource bool
+		want       string
+	}{
+		{"single values", testHit{fields: []byte(`{"a":[1],"b":["x"]}`)}, false, `{"a":1,"b":"x"}`},
+		{"arrays", testHit{fields: []byte(`{"a":[1,2],"b":[]}`)}, false, `{"a":[1,2],"b":[]}`},
+		{"no fields", testHit{}, false, `{}`},
+		{"merged into source", testHit{source: []byte(`{"a":0,"c":true}`), fields: []byte(`{"a":[1]}`)}, true, `{"a":1,"c":true}`},
+		{"source ignored", testHit{source: []byte(`{"c":true}`), fields: []byte(`{"a":[1]}`)}, false, `{"a":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hit := newFieldsSearchHit(tt.hit, tt.withSource, nil)
+			if string(hit.GetSource()) != tt.want {
+				t.Errorf("newFieldsSearchHit() source = %s, want %s", hit.GetSource(), tt.want)
+			}
+		})
+	}
+}
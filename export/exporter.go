@@ -0,0 +1,224 @@
+package export
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	elasticsearch "github.com/pteich/elastic-query-export/elastic"
+	"github.com/pteich/elastic-query-export/flags"
+)
+
+// Exporter exports the documents of a query to a file, an io.Writer or a sink. It is configured with
+// options and can be embedded in other programs, the CLI is a thin wrapper around it.
+//
+//	exporter := export.New(
+//		export.WithConnection(export.Connection{URL: "http://localhost:9200", Version: 8}),
+//		export.WithIndex("logs-*"),
+//		export.WithQuery("level:error"),
+//		export.WithFields("@timestamp", "message"),
+//		export.WithFormat(flags.FormatJSON),
+//		export.WithWriter(w),
+//	)
+//	result, err := exporter.Run(ctx)
+type Exporter struct {
+	conf             flags.Flags
+	flags            *flags.Flags
+	writer           io.Writer
+	logger           *log.Logger
+	onProgress       func(ProgressEvent)
+	progressInterval time.Duration
+	summaryOnSignal  bool
+	hooks            []Hook
+}
+
+// Option configures an Exporter.
+type Option func(*Exporter)
+
+// Connection are the settings to connect to the cluster.
+type Connection struct {
+	URL       string
+	User      string
+	Pass      string
+	Version   int
+	VerifySSL bool
+	// ClientCert and ClientKey are paths to a client certificate and its key
+	ClientCert string
+	ClientKey  string
+}
+
+// Hook observes or transforms the hits before they are written. Returning a nil hit drops it,
+// returning an error stops the export. Use ReplaceSource to change the source of a hit.
+type Hook interface {
+	Hit(ctx context.Context, hit elasticsearch.SearchHit) (elasticsearch.SearchHit, error)
+}
+
+// HookFunc is a function used as Hook.
+type HookFunc func(ctx context.Context, hit elasticsearch.SearchHit) (elasticsearch.SearchHit, error)
+
+func (f HookFunc) Hit(ctx context.Context, hit elasticsearch.SearchHit) (elasticsearch.SearchHit, error) {
+	return f(ctx, hit)
+}
+
+// New creates an Exporter with the defaults of the CLI. Progress is not reported and messages are
+// logged to the standard logger unless set with options.
+func New(options ...Option) *Exporter {
+	e := &Exporter{
+		conf:   flags.Defaults(),
+		writer: os.Stdout,
+		logger: log.Default(),
+	}
+	e.conf.Progress = flags.ProgressNone
+
+	// the settings of WithFlags are the base that all other options change, wherever it is given
+	base := &Exporter{}
+	for _, option := range options {
+		option(base)
+	}
+	if base.flags != nil {
+		e.conf = *base.flags
+	}
+
+	for _, option := range options {
+		option(e)
+	}
+	return e
+}
+
+// WithFlags uses all settings of the CLI flags instead of the defaults. It is applied before all
+// other options, so they change the settings of conf no matter if they are given before or after it.
+// If it is given several times, the last one is used.
+func WithFlags(conf *flags.Flags) Option {
+	return func(e *Exporter) {
+		e.flags = conf
+	}
+}
+
+// WithConnection sets the connection to the cluster.
+func WithConnection(connection Connection) Option {
+	return func(e *Exporter) {
+		e.conf.ElasticURL = connection.URL
+		e.conf.ElasticUser = connection.User
+		e.conf.ElasticPass = connection.Pass
+		e.conf.ElasticVerifySSL = connection.VerifySSL
+		e.conf.ElasticClientCrt = connection.ClientCert
+		e.conf.ElasticClientKey = connection.ClientKey
+		if connection.Version != 0 {
+			e.conf.ElasticVersion = connection.Version
+		}
+	}
+}
+
+// WithIndex sets the index, or index pattern, to export.
+func WithIndex(index string) Option {
+	return func(e *Exporter) {
+		e.conf.Index = index
+	}
+}
+
+// WithQuery sets a Lucene query like it is used in the Kibana search input.
+func WithQuery(query string) Option {
+	return func(e *Exporter) {
+		e.conf.Query = query
+		e.conf.RAWQuery = ""
+	}
+}
+
+// WithRawQuery sets a query in the Elasticsearch query DSL as JSON.
+func WithRawQuery(query string) Option {
+	return func(e *Exporter) {
+		e.conf.RAWQuery = query
+	}
+}
+
+// WithTimeRange limits the export to documents with field between start and end, either can be empty.
+func WithTimeRange(field, start, end string) Option {
+	return func(e *Exporter) {
+		e.conf.Timefield = field
+		e.conf.StartDate = start
+		e.conf.EndDate = end
+	}
+}
+
+// WithFields sets the exported fields, with the same column spec as --fields.
+func WithFields(fields ...string) Option {
+	return func(e *Exporter) {
+		e.conf.Fieldlist = strings.Join(fields, ",")
+	}
+}
+
+// WithExcludeFields sets fields that are not exported.
+func WithExcludeFields(fields ...string) Option {
+	return func(e *Exporter) {
+		e.conf.ExcludeFieldlist = strings.Join(fields, ",")
+	}
+}
+
+// WithFormat sets the output format, one of the flags.Format constants.
+func WithFormat(format string) Option {
+	return func(e *Exporter) {
+		e.conf.OutFormat = format
+	}
+}
+
+// WithWriter writes the output to w instead of a file. w is not closed, formats that create files
+// themselves and split or partitioned outputs can not be written to it.
+func WithWriter(w io.Writer) Option {
+	return func(e *Exporter) {
+		e.writer = w
+		e.conf.Outfile = "-"
+	}
+}
+
+// WithProgress calls fn with a progress event every interval and once more when the export is done.
+func WithProgress(interval time.Duration, fn func(ProgressEvent)) Option {
+	return func(e *Exporter) {
+		e.onProgress = fn
+		e.progressInterval = interval
+		e.conf.Progress = flags.ProgressJSON
+	}
+}
+
+// WithSummarySignal prints a summary of the progress on SIGUSR1, on platforms that have it.
+func WithSummarySignal() Option {
+	return func(e *Exporter) {
+		e.summaryOnSignal = true
+	}
+}
+
+// WithLogger logs warnings and messages of the export to logger instead of the standard logger.
+func WithLogger(logger *log.Logger) Option {
+	return func(e *Exporter) {
+		e.logger = logger
+	}
+}
+
+// WithHook adds a hook that is called for every hit in the order the hooks were added.
+func WithHook(hook Hook) Option {
+	return func(e *Exporter) {
+		e.hooks = append(e.hooks, hook)
+	}
+}
+
+// ReplaceSource returns hit with source as its new source, for hooks that transform documents.
+func ReplaceSource(hit elasticsearch.SearchHit, source []byte) elasticsearch.SearchHit {
+	return &sourceSearchHit{SearchHit: hit, source: source}
+}
+
+// sourceSearchHit is a hit with a replaced source.
+type sourceSearchHit struct {
+	elasticsearch.SearchHit
+	source []byte
+}
+
+func (h *sourceSearchHit) GetSource() []byte {
+	return h.source
+}
+
+// Run exports the documents matching the query of conf with the CLI settings, see Exporter.Run.
+func Run(ctx context.Context, conf *flags.Flags) (*Result, error) {
+	return New(WithFlags(conf)).Run(ctx)
+}
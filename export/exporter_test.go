@@ -0,0 +1,390 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	elasticsearch "github.com/pteich/elastic-query-export/elastic"
+	"github.com/pteich/elastic-query-export/flags"
+)
+
+func TestExporter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/logs/_count":
+			fmt.Fprint(w, `{"count":3}`)
+		case "/logs/_search":
+			fmt.Fprint(w, `{"_scroll_id":"scroll-1","hits":{"total":{"value":3},"hits":[
+				{"_id":"1","_index":"logs","_source":{"n":1}},
+				{"_id":"2","_index":"logs","_source":{"n":2}},
+				{"_id":"3","_index":"logs","_source":{"n":3}}]}}`)
+		case "/_search/scroll":
+			fmt.Fprint(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	// drop the second document and replace the source of the others
+	hook := HookFunc(func(ctx context.Context, hit elasticsearch.SearchHit) (elasticsearch.SearchHit, error) {
+		if hit.GetID() == "2" {
+			return nil, nil
+		}
+		return ReplaceSource(hit, []byte(`{"id":"`+hit.GetID()+`"}`)), nil
+	})
+
+	var events []ProgressEvent
+	var buf bytes.Buffer
+	exporter := New(
+		WithConnection(Connection{URL: server.URL, Version: 8}),
+		WithIndex("logs"),
+		WithQuery("*"),
+		WithFormat(flags.FormatJSON),
+		WithWriter(&buf),
+		WithHook(hook),
+		WithProgress(time.Hour, func(e ProgressEvent) { events = append(events, e) }),
+		WithLogger(log.New(io.Discard, "", 0)),
+	)
+
+	result, err := exporter.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if want := "{\"id\":\"1\"}\n{\"id\":\"3\"}\n"; buf.String() != want {
+		t.Errorf("Run() wrote %q, want %q", buf.String(), want)
+	}
+	if result.Total != 3 || result.Read != 3 || result.Written != 2 || result.Skipped != 0 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if len(events) != 1 || events[0].Event != "done" || events[0].Docs != 2 {
+		t.Errorf("unexpected progress events %+v", events)
+	}
+}
+
+func TestNewWithFlags(t *testing.T) {
+	conf := flags.Defaults()
+	conf.Index = "flags-*"
+	conf.Query = "level:error"
+	conf.ScrollSize = 250
+
+	// options change the settings of WithFlags, given before or after it
+	for _, e := range []*Exporter{
+		New(WithIndex("logs-*"), WithFlags(&conf)),
+		New(WithFlags(&conf), WithIndex("logs-*")),
+	} {
+		if e.conf.Index != "logs-*" || e.conf.Query != "level:error" || e.conf.ScrollSize != 250 {
+			t.Errorf("unexpected settings index %s, query %s, size %d", e.conf.Index, e.conf.Query, e.conf.ScrollSize)
+		}
+	}
+
+	if e := New(WithIndex("logs-*")); e.conf.Index != "logs-*" || e.conf.Progress != flags.ProgressNone {
+		t.Errorf("unexpected settings without flags index %s, progress %s", e.conf.Index, e.conf.Progress)
+	}
+}
+
+func TestExporterManifest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `{"cluster_uuid":"abc123","version":{"number":"8.15.0"}}`)
+		case "/_cat/indices/logs":
+			fmt.Fprint(w, `[{"index":"logs"}]`)
+		case "/logs/_count":
+			fmt.Fprint(w, `{"count":1}`)
+		case "/logs/_search":
+			fmt.Fprint(w, `{"_scroll_id":"scroll-1","hits":{"total":{"value":1},"hits":[
+				{"_id":"1","_index":"logs","_source":{"n":1}}]}}`)
+		case "/_search/scroll":
+			fmt.Fprint(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	for _, version := range []int{7, 8, 9} {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			dir := t.TempDir()
+			conf := flags.Defaults()
+			conf.ElasticURL = server.URL
+			conf.ElasticVersion = version
+			conf.Index = "logs"
+			conf.Fieldlist = "n"
+			conf.Sort = "n:desc"
+			conf.ScrollSize = 500
+			conf.Outfile = filepath.Join(dir, "logs.csv")
+			conf.Manifest = filepath.Join(dir, "manifest.json")
+			conf.Progress = flags.ProgressNone
+
+			if _, err := New(WithFlags(&conf), WithLogger(log.New(io.Discard, "", 0))).Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			data, err := os.ReadFile(conf.Manifest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got struct {
+				Query map[string]interface{} `json:"query"`
+			}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+
+			// the manifest holds the search body with everything that changes the exported documents
+			for _, key := range []string{"query", "sort", "_source", "size"} {
+				if _, ok := got.Query[key]; !ok {
+					t.Errorf("manifest query %v has no %s", got.Query, key)
+				}
+			}
+			if got.Query["size"] != 500.0 {
+				t.Errorf("manifest query has size %v, want 500", got.Query["size"])
+			}
+		})
+	}
+}
+
+func TestExporterAvroSchema(t *testing.T) {
+	tests := []struct {
+		name string
+		conf func(conf *flags.Flags)
+	}{
+		{"partitioned", func(conf *flags.Flags) {
+			conf.PartitionBy = "level"
+			conf.Outfile = t.TempDir() + "/schema.avsc"
+		}},
+		{"elasticsearch sink", func(conf *flags.Flags) {
+			conf.Sink = flags.SinkElasticsearch
+			conf.TargetURL = "http://127.0.0.1:1"
+		}},
+		{"http sink", func(conf *flags.Flags) {
+			conf.Sink = flags.SinkHTTP
+			conf.SinkURL = "http://127.0.0.1:1"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := flags.Defaults()
+			conf.ElasticURL = "http://127.0.0.1:1"
+			conf.ElasticVersion = 8
+			conf.OutFormat = flags.FormatAvro
+			conf.AvroSchema = true
+			conf.Progress = flags.ProgressNone
+			tt.conf(&conf)
+
+			_, err := New(WithFlags(&conf), WithLogger(log.New(io.Discard, "", 0))).Run(context.Background())
+			if err == nil || !strings.Contains(err.Error(), "Avro schema") {
+				t.Errorf("Run() error = %v, want a rejected Avro schema", err)
+			}
+		})
+	}
+}
+
+func TestExporterArrowFormat(t *testing.T) {
+	conf := flags.Defaults()
+	conf.ElasticURL = "http://127.0.0.1:1"
+	conf.ElasticVersion = 8
+	conf.OutFormat = flags.FormatArrow
+	conf.ArrowFormat = "strem"
+	conf.Outfile = t.TempDir() + "/logs.arrow"
+
+	_, err := New(WithFlags(&conf), WithLogger(log.New(io.Discard, "", 0))).Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "unknown arrow format") {
+		t.Errorf("Run() error = %v, want an unknown arrow format", err)
+	}
+}
+
+func TestExporterWildcardColumns(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/logs/_count":
+			fmt.Fprint(w, `{"count":2}`)
+		case "/logs/_mapping":
+			fmt.Fprint(w, `{"logs":{"mappings":{"properties":{"user":{"properties":{
+				"id":{"type":"long"},"name":{"type":"keyword"}}}}}}}`)
+		case "/logs/_search":
+			fmt.Fprint(w, `{"_scroll_id":"scroll-1","hits":{"total":{"value":2},"hits":[
+				{"_id":"1","_index":"logs","_source":{"user":{"id":1}}},
+				{"_id":"2","_index":"logs","_source":{"user":{"id":2,"name":"jane"}}}]}}`)
+		case "/_search/scroll":
+			fmt.Fprint(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	exporter := New(
+		WithConnection(Connection{URL: server.URL, Version: 8}),
+		WithIndex("logs"),
+		WithFields("_id", "u=user.*"),
+		WithFormat(flags.FormatCSV),
+		WithWriter(&buf),
+		WithLogger(log.New(io.Discard, "", 0)),
+	)
+
+	if _, err := exporter.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// the name is missing in the first document but part of the mapping, rows are written by several workers
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	sort.Strings(lines[1:])
+	if want := []string{"_id,u.id,u.name", "1,1,", "2,2,jane"}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("Run() wrote %q, want %q", lines, want)
+	}
+}
+
+func TestExporterSQLite(t *testing.T) {
+	failSearch := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/logs/_count":
+			fmt.Fprint(w, `{"count":1}`)
+		case "/logs/_mapping":
+			fmt.Fprint(w, `{"logs":{"mappings":{"properties":{"n":{"type":"long"}}}}}`)
+		case "/logs/_search":
+			if failSearch {
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprint(w, `{"error":"unavailable"}`)
+				return
+			}
+			fmt.Fprint(w, `{"_scroll_id":"scroll-1","hits":{"total":{"value":1},"hits":[
+				{"_id":"1","_index":"logs","_source":{"n":1}}]}}`)
+		case "/_search/scroll":
+			fmt.Fprint(w, `{}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs.db")
+
+	run := func(appending bool) error {
+		conf := flags.Defaults()
+		conf.ElasticURL = server.URL
+		conf.ElasticVersion = 8
+		conf.Index = "logs"
+		conf.OutFormat = flags.FormatSQLite
+		conf.Outfile = path
+		conf.Append = appending
+		conf.Progress = flags.ProgressNone
+		_, err := New(WithFlags(&conf), WithLogger(log.New(io.Discard, "", 0))).Run(context.Background())
+		return err
+	}
+
+	if err := run(false); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if err := run(true); err != nil {
+		t.Fatalf("Run() with append error = %v", err)
+	}
+	exported, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// failed exports leave the existing database unchanged, with and without append
+	failSearch = true
+	for _, appending := range []bool{false, true} {
+		if err := run(appending); err == nil {
+			t.Fatalf("Run() expected error")
+		}
+		if data, _ := os.ReadFile(path); !bytes.Equal(data, exported) {
+			t.Errorf("failed export with append %v changed the database", appending)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only the database, got %d files", len(entries))
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM logs`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 appended rows, got %d", count)
+	}
+}
+
+func TestExporterConfigError(t *testing.T) {
+	for _, version := range []int{6, 10} {
+		_, err := New(WithConnection(Connection{URL: "http://127.0.0.1:1", Version: version}), WithWriter(io.Discard), WithLogger(log.New(io.Discard, "", 0))).Run(context.Background())
+		if err == nil || errors.Is(err, ErrConnection) || errors.Is(err, ErrAuth) || errors.Is(err, ErrQuery) {
+			t.Errorf("Run() with version %d error = %v, want an unclassified error", version, err)
+		}
+	}
+
+	conf := flags.Defaults()
+	conf.ElasticURL = "http://127.0.0.1:1"
+	conf.ElasticVersion = 8
+	conf.RAWQuery = `{"match_all":`
+	_, err := New(WithFlags(&conf), WithWriter(io.Discard), WithLogger(log.New(io.Discard, "", 0))).Run(context.Background())
+	if !errors.Is(err, ErrQuery) {
+		t.Errorf("Run() with invalid raw query error = %v, want %v", err, ErrQuery)
+	}
+}
+
+func TestExporterAuthError(t *testing.T) {
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"error":{"type":"security_exception"},"status":`+fmt.Sprint(status)+`}`)
+		}))
+
+		for _, version := range []int{7, 8, 9} {
+			t.Run(fmt.Sprintf("%d v%d", status, version), func(t *testing.T) {
+				exporter := New(
+					WithConnection(Connection{URL: server.URL, User: "elastic", Pass: "wrong", Version: version}),
+					WithIndex("logs"),
+					WithWriter(io.Discard),
+					WithLogger(log.New(io.Discard, "", 0)),
+				)
+				if _, err := exporter.Run(context.Background()); !errors.Is(err, ErrAuth) {
+					t.Errorf("Run() error = %v, want %v", err, ErrAuth)
+				}
+			})
+		}
+
+		server.Close()
+	}
+}
@@ -0,0 +1,244 @@
+package export
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	elasticsearch "github.com/pteich/elastic-query-export/elastic"
+	"github.com/pteich/elastic-query-export/flags"
+)
+
+// manifest is written with --manifest next to the export to prove what was exported.
+type manifest struct {
+	ToolVersion string          `json:"tool_version"`
+	Cluster     manifestCluster `json:"cluster"`
+	Index       string          `json:"index"`
+	Indices     []string        `json:"indices"`
+	Query       json.RawMessage `json:"query"`
+	TimeRange   manifestRange   `json:"time_range"`
+	Total       int64           `json:"total"`
+	Written     int64           `json:"written"`
+	Files       []manifestFile  `json:"files"`
+	Started     time.Time       `json:"started"`
+	Finished    time.Time       `json:"finished"`
+	PublicKey   string          `json:"public_key,omitempty"`
+
+	key ed25519.PrivateKey
+}
+
+type manifestCluster struct {
+	URL     string `json:"url"`
+	UUID    string `json:"uuid"`
+	Version string `json:"version"`
+}
+
+type manifestRange struct {
+	Field string `json:"field"`
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+type manifestFile struct {
+	Path   string `json:"path"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// outputRecorder remembers all opened output files, so the completed ones can be listed in the manifest
+// and the written bytes can be reported as progress.
+type outputRecorder struct {
+	mu      sync.Mutex
+	paths   []string
+	outputs []*outputFile
+}
+
+func (r *outputRecorder) add(path string, out *outputFile) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paths = append(r.paths, path)
+	r.outputs = append(r.outputs, out)
+}
+
+// written returns the number of bytes written to all outputs.
+func (r *outputRecorder) written() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for _, out := range r.outputs {
+		n += out.Written()
+	}
+	return n
+}
+
+// files returns size and hash of all outputs that were closed without being aborted.
+func (r *outputRecorder) files() []manifestFile {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var files []manifestFile
+	for i, out := range r.outputs {
+		if out.aborted {
+			continue
+		}
+		files = append(files, manifestFile{Path: r.paths[i], Bytes: out.Written(), SHA256: out.SHA256()})
+	}
+	return files
+}
+
+// newManifest collects the information about the cluster and the search request body of an export.
+func newManifest(ctx context.Context, conf *flags.Flags, body map[string]interface{}) (*manifest, error) {
+	m := &manifest{
+		ToolVersion: conf.Version,
+		Index:       conf.Index,
+		TimeRange:   manifestRange{Field: conf.Timefield, Start: conf.StartDate, End: conf.EndDate},
+	}
+
+	var err error
+	if m.Query, err = json.Marshal(body); err != nil {
+		return nil, err
+	}
+
+	if conf.ManifestKey != "" {
+		if m.key, err = loadSigningKey(conf.ManifestKey); err != nil {
+			return nil, err
+		}
+		m.PublicKey = base64.StdEncoding.EncodeToString(m.key.Public().(ed25519.PublicKey))
+	}
+
+	m.Cluster.URL = conf.ElasticURL
+	if u, err := url.Parse(conf.ElasticURL); err == nil {
+		// the username is a credential as well, Redacted would only mask the password
+		u.User = nil
+		m.Cluster.URL = u.String()
+	}
+
+	client, err := newHTTPClient(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	var info struct {
+		ClusterUUID string `json:"cluster_uuid"`
+		Version     struct {
+			Number string `json:"number"`
+		} `json:"version"`
+	}
+	if err := getJSON(ctx, client, conf, "/", &info); err != nil {
+		return nil, elasticError("reading cluster info", err)
+	}
+	m.Cluster.UUID, m.Cluster.Version = info.ClusterUUID, info.Version.Number
+
+	var indices []struct {
+		Index string `json:"index"`
+	}
+	if err := getJSON(ctx, client, conf, "/_cat/indices/"+url.PathEscape(conf.Index)+"?h=index&format=json", &indices); err != nil {
+		return nil, elasticError("reading indices", err)
+	}
+	for _, index := range indices {
+		m.Indices = append(m.Indices, index.Index)
+	}
+
+	return m, nil
+}
+
+// getJSON decodes the response of a GET request to the cluster into v. Error responses are returned as
+// StatusError, so they are classified like errors of the clients.
+func getJSON(ctx context.Context, client *http.Client, conf *flags.Flags, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(conf.ElasticURL, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	if conf.ElasticUser != "" && conf.ElasticPass != "" {
+		req.SetBasicAuth(conf.ElasticUser, conf.ElasticPass)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &elasticsearch.StatusError{Status: resp.StatusCode, Message: fmt.Sprintf("request failed with status %d: %s", resp.StatusCode, body)}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// hashFile returns size and hash of a file that was not written through an outputFile, like SQLite databases.
+func hashFile(path string) (manifestFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return manifestFile{}, err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, file)
+	if err != nil {
+		return manifestFile{}, err
+	}
+
+	return manifestFile{Path: path, Bytes: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// write writes the manifest to path. With a signing key the base64 encoded ed25519 signature of the
+// written manifest is stored in path.sig.
+func (m *manifest) write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+
+	if m.key == nil {
+		return nil
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(m.key, data))
+	return os.WriteFile(path+".sig", []byte(signature+"\n"), 0o644)
+}
+
+// loadSigningKey reads a PEM encoded PKCS #8 ed25519 private key, as created by openssl genpkey -algorithm ed25519.
+func loadSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded key found in " + path)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	ed25519Key, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("key in %s is no ed25519 key", path)
+	}
+	return ed25519Key, nil
+}
@@ -0,0 +1,154 @@
+package export
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	elasticv8 "github.com/pteich/elastic-query-export/elastic/v8"
+	"github.com/pteich/elastic-query-export/flags"
+)
+
+func Test_manifest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `{"cluster_uuid":"abc123","version":{"number":"8.15.0"}}`)
+		case "/_cat/indices/logs-*":
+			fmt.Fprint(w, `[{"index":"logs-2026.10.17"},{"index":"logs-2026.10.18"}]`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := &flags.Flags{
+		ElasticURL:  strings.Replace(server.URL, "http://", "http://elastic:secret@", 1),
+		Index:       "logs-*",
+		Timefield:   "@timestamp",
+		StartDate:   "2026-10-17",
+		ManifestKey: keyFile,
+		Version:     "1.2.3",
+	}
+	body := map[string]interface{}{"query": elasticv8.NewBoolQuery().Must(elasticv8.NewMatchAllQuery()).Build()}
+
+	m, err := newManifest(context.Background(), conf, body)
+	if err != nil {
+		t.Fatalf("newManifest() error = %v", err)
+	}
+
+	outputs := &outputRecorder{}
+	for _, name := range []string{"output.csv", "failed.csv"} {
+		out, err := openOutput(filepath.Join(dir, name), flags.CompressNone, 0, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprint(out, "a,b\n")
+		if name == "failed.csv" {
+			out.Abort()
+		}
+		out.Close()
+		outputs.add(filepath.Join(dir, name), out)
+	}
+	m.Files = outputs.files()
+
+	path := filepath.Join(dir, "manifest.json")
+	if err := m.write(path); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := os.ReadFile(path + ".sig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil || !ed25519.Verify(private.Public().(ed25519.PublicKey), data, sig) {
+		t.Errorf("invalid manifest signature")
+	}
+
+	var got manifest
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(got.Cluster.URL, "secret") || strings.Contains(got.Cluster.URL, "elastic@") {
+		t.Errorf("credentials not removed from %s", got.Cluster.URL)
+	}
+	if got.Cluster.UUID != "abc123" || got.Cluster.Version != "8.15.0" || got.ToolVersion != "1.2.3" {
+		t.Errorf("unexpected cluster %+v", got.Cluster)
+	}
+	if len(got.Indices) != 2 || got.Indices[0] != "logs-2026.10.17" {
+		t.Errorf("unexpected indices %v", got.Indices)
+	}
+	if !strings.Contains(string(got.Query), `"match_all"`) {
+		t.Errorf("unexpected query %s", got.Query)
+	}
+
+	// sha256 of "a,b\n"
+	want := []manifestFile{{Path: filepath.Join(dir, "output.csv"), Bytes: 4, SHA256: "5be08c9684a1d25efcee09318204824278b08bbfb4aef973ffefd0b9d7478313"}}
+	if len(got.Files) != 1 || got.Files[0] != want[0] {
+		t.Errorf("unexpected files %+v, want %+v", got.Files, want)
+	}
+}
+
+func Test_newManifestError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		path   string
+		want   error
+	}{
+		{"forbidden cluster info", http.StatusForbidden, "/", ErrAuth},
+		{"unauthorized indices", http.StatusUnauthorized, "/_cat/indices/logs-*", ErrAuth},
+		{"unavailable", http.StatusServiceUnavailable, "/", ErrConnection},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == tt.path {
+					w.WriteHeader(tt.status)
+					return
+				}
+				fmt.Fprint(w, `{}`)
+			}))
+			defer server.Close()
+
+			conf := &flags.Flags{ElasticURL: server.URL, Index: "logs-*"}
+			_, err := newManifest(context.Background(), conf, nil)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("newManifest() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pteich/elastic-query-export/flags"
+)
+
+var outfilePlaceholder = regexp.MustCompile(`\{(index|start|end|date|format|hostname)(?::([^}]+))?\}`)
+
+// unsafeFilename replaces characters that are not allowed in file names on all platforms.
+var unsafeFilename = strings.NewReplacer("*", "", "?", "", ",", "_", "/", "-", "\\", "-", ":", "-")
+
+// outfileName replaces the placeholders {index}, {start}, {end}, {date}, {format} and {hostname} of
+// the output file name. {date} is the current date and accepts a Go time layout like {date:20060102-15}.
+// The extension of the output format, and compression, is added to names without extension. Encrypted
+// outputs always get the .age extension.
+func outfileName(conf *flags.Flags, now time.Time) (string, error) {
+	if conf.Outfile == "-" {
+		return conf.Outfile, nil
+	}
+
+	var err error
+	name := outfilePlaceholder.ReplaceAllStringFunc(conf.Outfile, func(placeholder string) string {
+		match := outfilePlaceholder.FindStringSubmatch(placeholder)
+		switch match[1] {
+		case "index":
+			return strings.Trim(unsafeFilename.Replace(conf.Index), "-_.")
+		case "start":
+			return unsafeFilename.Replace(conf.StartDate)
+		case "end":
+			return unsafeFilename.Replace(conf.EndDate)
+		case "date":
+			layout := match[2]
+			if layout == "" {
+				layout = "2006-01-02"
+			}
+			return unsafeFilename.Replace(now.Format(layout))
+		case "format":
+			return conf.OutFormat
+		case "hostname":
+			hostname, herr := os.Hostname()
+			if herr != nil {
+				err = herr
+			}
+			return unsafeFilename.Replace(hostname)
+		}
+		return placeholder
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// the extension is taken from the pattern, since values like {start} can contain dots
+	if filepath.Ext(outfilePlaceholder.ReplaceAllString(conf.Outfile, "")) == "" {
+		name += formatExtension(conf)
+		switch conf.Compress {
+		case flags.CompressGzip:
+			name += ".gz"
+		case flags.CompressZstd:
+			name += ".zst"
+		}
+	}
+
+	if encrypted(conf) && !strings.HasSuffix(name, ".age") {
+		name += ".age"
+	}
+
+	return name, nil
+}
+
+// formatExtension returns the usual file extension of the output format.
+func formatExtension(conf *flags.Flags) string {
+	switch conf.OutFormat {
+	case flags.FormatJSON, flags.FormatRAW:
+		return ".json"
+	case flags.FormatParquet:
+		return ".parquet"
+	case flags.FormatArrow:
+		if conf.ArrowFormat == flags.ArrowFormatStream {
+			return ".arrows"
+		}
+		return ".arrow"
+	case flags.FormatAvro:
+		if conf.AvroSchema {
+			return ".avsc"
+		}
+		return ".avro"
+	case flags.FormatXLSX:
+		return ".xlsx"
+	case flags.FormatSQLite:
+		return ".db"
+	case flags.FormatBulk:
+		return ".ndjson"
+	case flags.FormatTemplate:
+		return ".txt"
+	default:
+		return ".csv"
+	}
+}
@@ -0,0 +1,47 @@
+package export
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pteich/elastic-query-export/flags"
+)
+
+func Test_outfileName(t *testing.T) {
+	now := time.Date(2026, 10, 17, 13, 45, 0, 0, time.UTC)
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		conf flags.Flags
+		want string
+	}{
+		{"fixed name", flags.Flags{Outfile: "export.csv", OutFormat: flags.FormatJSON}, "export.csv"},
+		{"stdout", flags.Flags{Outfile: "-", OutFormat: flags.FormatCSV}, "-"},
+		{"extension from format", flags.Flags{Outfile: "output", OutFormat: flags.FormatCSV}, "output.csv"},
+		{"extension with compression", flags.Flags{Outfile: "output", OutFormat: flags.FormatJSON, Compress: flags.CompressGzip}, "output.json.gz"},
+		{"index and date", flags.Flags{Outfile: "{index}-{date}", Index: "logs-*", OutFormat: flags.FormatParquet}, "logs-2026-10-17.parquet"},
+		{"date layout", flags.Flags{Outfile: "export-{date:20060102-15}.{format}", OutFormat: flags.FormatXLSX}, "export-20261017-13.xlsx"},
+		{"start and end", flags.Flags{Outfile: "{start}_{end}", StartDate: "2026-10-17T00:00:00.000Z", EndDate: "2026-10-18", OutFormat: flags.FormatBulk}, "2026-10-17T00-00-00.000Z_2026-10-18.ndjson"},
+		{"hostname", flags.Flags{Outfile: "exports/{hostname}.csv", OutFormat: flags.FormatCSV}, "exports/" + hostname + ".csv"},
+		{"encrypted", flags.Flags{Outfile: "output", OutFormat: flags.FormatCSV, Compress: flags.CompressZstd, EncryptTo: "age1..."}, "output.csv.zst.age"},
+		{"encrypted with extension", flags.Flags{Outfile: "export.csv", OutFormat: flags.FormatCSV, EncryptTo: "age1..."}, "export.csv.age"},
+		{"part number is kept", flags.Flags{Outfile: "{index}-{n:04}", Index: "logs", OutFormat: flags.FormatCSV}, "logs-{n:04}.csv"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := outfileName(&tt.conf, now)
+			if err != nil {
+				t.Fatalf("outfileName() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("outfileName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+
+	"filippo.io/age"
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+
+	"github.com/pteich/elastic-query-export/flags"
+)
+
+// outputFile is the writer formatters write to. It compresses and encrypts the data if needed and
+// closes the compressor, the encryption and the underlying file on Close.
+type outputFile struct {
+	io.Writer
+	closers []io.Closer
+	counter *countingWriter
+	// abort discards the written data for destinations that support it, like S3 uploads
+	abort   func()
+	aborted bool
+}
+
+// countingWriter counts and hashes the bytes written to the underlying writer.
+type countingWriter struct {
+	io.Writer
+	written atomic.Int64
+	hash    hash.Hash
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.written.Add(int64(n))
+	w.hash.Write(p[:n])
+	return n, err
+}
+
+// Written returns the number of bytes written to the file after compression and encryption.
+func (o *outputFile) Written() int64 {
+	return o.counter.written.Load()
+}
+
+// SHA256 returns the hex encoded SHA-256 hash of the data written to the file, it is complete after Close.
+func (o *outputFile) SHA256() string {
+	return hex.EncodeToString(o.counter.hash.Sum(nil))
+}
+
+// Close closes the compressor, the encryption and the file, closing an already closed output does nothing.
+// If the compressor fails, the output is aborted since the file would be incomplete.
+func (o *outputFile) Close() error {
+	var err error
+	for _, closer := range o.closers {
+		if cerr := closer.Close(); cerr != nil && err == nil {
+			err = cerr
+			o.Abort()
+		}
+	}
+	o.closers = nil
+	return err
+}
+
+// Abort discards the output of a failed export if the destination supports it. Writes fail
+// afterwards and the output still has to be closed.
+func (o *outputFile) Abort() {
+	o.aborted = true
+	if o.abort != nil {
+		o.abort()
+	}
+}
+
+// compression returns the codec set with --compress or detected from the extension of the output file.
+func compression(conf *flags.Flags) string {
+	if conf.Compress != "" {
+		return conf.Compress
+	}
+
+	name := strings.TrimSuffix(conf.Outfile, ".age")
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		return flags.CompressGzip
+	case strings.HasSuffix(name, ".zst"), strings.HasSuffix(name, ".zstd"):
+		return flags.CompressZstd
+	default:
+		return flags.CompressNone
+	}
+}
+
+// openOutput creates the file at path, or uses stdout for -, and wraps it with the given compression
+// and the age encryption to the recipients, if any. Files are written atomically, see atomicFile.
+func openOutput(path, codec string, level int, recipients []age.Recipient, keepPartial bool) (*outputFile, error) {
+	if path == "-" {
+		return newOutputFile(os.Stdout, nil, nil, codec, level, recipients)
+	}
+
+	file, err := createAtomic(path, keepPartial)
+	if err != nil {
+		return nil, err
+	}
+	return newOutputFile(file, file, file.abort, codec, level, recipients)
+}
+
+// newOutputFile wraps w with the given compression and encrypts the compressed data to the recipients,
+// if any. The closer of w, if any, is closed last and abort is called for failed exports.
+func newOutputFile(w io.Writer, closer io.Closer, abort func(), codec string, level int, recipients []age.Recipient) (*outputFile, error) {
+	out := &outputFile{abort: abort}
+	if closer != nil {
+		out.closers = append(out.closers, closer)
+	}
+
+	out.counter = &countingWriter{Writer: w, hash: sha256.New()}
+	out.Writer = out.counter
+
+	if len(recipients) > 0 {
+		encryptor, err := age.Encrypt(out.Writer, recipients...)
+		if err != nil {
+			out.Abort()
+			out.Close()
+			return nil, err
+		}
+		out.Writer = encryptor
+		// the encryption writes the last chunk on Close, before the file is closed
+		out.closers = append([]io.Closer{encryptor}, out.closers...)
+	}
+
+	compressor, err := newCompressor(out.Writer, codec, level)
+	if err != nil {
+		out.Abort()
+		out.Close()
+		return nil, err
+	}
+
+	if compressor != nil {
+		out.Writer = compressor
+		// the compressor has to be closed first to flush all data to the file
+		out.closers = append([]io.Closer{compressor}, out.closers...)
+	}
+
+	return out, nil
+}
+
+// newCompressor returns a writer that compresses with all available CPUs, or nil if no compression is used.
+// A level of 0 uses the default level of the codec.
+func newCompressor(w io.Writer, codec string, level int) (io.WriteCloser, error) {
+	switch codec {
+	case "", flags.CompressNone:
+		return nil, nil
+	case flags.CompressGzip:
+		if level == 0 {
+			level = pgzip.DefaultCompression
+		}
+		gw, err := pgzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, err
+		}
+		if err := gw.SetConcurrency(1<<20, runtime.GOMAXPROCS(0)); err != nil {
+			return nil, err
+		}
+		return gw, nil
+	case flags.CompressZstd:
+		options := []zstd.EOption{zstd.WithEncoderConcurrency(runtime.GOMAXPROCS(0))}
+		if level != 0 {
+			options = append(options, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		return zstd.NewWriter(w, options...)
+	default:
+		return nil, fmt.Errorf("unknown compression %s", codec)
+	}
+}
@@ -0,0 +1,82 @@
+package export
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+
+	"github.com/pteich/elastic-query-export/flags"
+)
+
+func Test_compression(t *testing.T) {
+	tests := []struct {
+		outfile  string
+		compress string
+		want     string
+	}{
+		{"output.csv", "", flags.CompressNone},
+		{"output.csv.gz", "", flags.CompressGzip},
+		{"output.ndjson.zst", "", flags.CompressZstd},
+		{"output.csv", flags.CompressZstd, flags.CompressZstd},
+		{"output.csv.gz", flags.CompressNone, flags.CompressNone},
+		{"output.csv.gz.age", "", flags.CompressGzip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.outfile+"/"+tt.compress, func(t *testing.T) {
+			if got := compression(&flags.Flags{Outfile: tt.outfile, Compress: tt.compress}); got != tt.want {
+				t.Errorf("compression() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_openOutput(t *testing.T) {
+	readers := map[string]func(io.Reader) (io.Reader, error){
+		flags.CompressNone: func(r io.Reader) (io.Reader, error) { return r, nil },
+		flags.CompressGzip: func(r io.Reader) (io.Reader, error) { return pgzip.NewReader(r) },
+		flags.CompressZstd: func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	}
+
+	for codec, reader := range readers {
+		t.Run(codec, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "output")
+			out, err := openOutput(path, codec, 0, nil, false)
+			if err != nil {
+				t.Fatalf("openOutput() error = %v", err)
+			}
+			if _, err := io.WriteString(out, "hello world\n"); err != nil {
+				t.Fatal(err)
+			}
+			if err := out.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			r, err := reader(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "hello world\n" {
+				t.Errorf("read %q", data)
+			}
+		})
+	}
+
+	if _, err := openOutput(filepath.Join(t.TempDir(), "output"), "lz4", 0, nil, false); err == nil {
+		t.Error("expected error for unknown compression")
+	}
+}
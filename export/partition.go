@@ -0,0 +1,143 @@
+package export
+
+import (
+	"container/list"
+	"context"
+	"io"
+	"os"
+
+	elasticsearch "github.com/pteich/elastic-query-export/elastic"
+	"github.com/pteich/elastic-query-export/formats"
+)
+
+// partitioner is a Formatter that writes every hit into a file below a Hive-style partition
+// directory like day=2026-10-17/host=web1. Every partition gets its own files named after pattern,
+// so CSV files have their own header. At most maxOpen files are open at the same time, the least
+// recently used one is finished when another partition needs a file. A partition that gets more
+// hits afterwards continues with its next part, as do partitions with rows or bytes reached.
+type partitioner struct {
+	root      string
+	pattern   string
+	keys      []formats.PartitionKey
+	maxOpen   int
+	rows      int
+	bytes     int64
+	open      func(path string) (*outputFile, error)
+	formatter func(w io.Writer, path string) (Formatter, error)
+	warnings  *formats.Warnings
+	parts     []splitPart
+
+	// lru holds the *openPartition of all open files, the most recently used at the front
+	lru     *list.List
+	files   map[string]*list.Element
+	counter map[string]int
+}
+
+type openPartition struct {
+	dir  string
+	part *partWriter
+}
+
+func (p *partitioner) Run(ctx context.Context, hits <-chan elasticsearch.SearchHit) error {
+	p.lru = list.New()
+	p.files = make(map[string]*list.Element)
+	p.counter = make(map[string]int)
+
+	for hit := range hits {
+		dir, err := formats.PartitionDir(p.keys, hit)
+		if err != nil {
+			// like the formatters of unpartitioned exports
+			p.warnings.Skip(1, "Error unmarshal JSON from ElasticSearch - %v", err)
+			continue
+		}
+
+		part, err := p.partition(ctx, dir)
+		if err != nil {
+			p.abortAll()
+			return err
+		}
+
+		if err := part.write(hit); err != nil {
+			p.abortAll()
+			return err
+		}
+	}
+
+	if err := context.Cause(ctx); err != nil {
+		p.abortAll()
+		return err
+	}
+
+	return p.closeAll()
+}
+
+// partition returns the open part of dir, starting a new one if there is none or the current one is full.
+func (p *partitioner) partition(ctx context.Context, dir string) (*partWriter, error) {
+	if elem, ok := p.files[dir]; ok {
+		current := elem.Value.(*openPartition)
+		if !current.part.full(p.rows, p.bytes) {
+			p.lru.MoveToFront(elem)
+			return current.part, nil
+		}
+		if err := p.finish(elem); err != nil {
+			return nil, err
+		}
+	}
+
+	for p.maxOpen > 0 && p.lru.Len() >= p.maxOpen {
+		if err := p.finish(p.lru.Back()); err != nil {
+			return nil, err
+		}
+	}
+
+	if !isS3(p.root) {
+		if err := os.MkdirAll(joinOutput(p.root, dir), 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	part, err := startPart(ctx, joinOutput(p.root, dir, partName(p.pattern, p.counter[dir])), p.open, p.formatter)
+	if err != nil {
+		return nil, err
+	}
+	p.counter[dir]++
+	p.files[dir] = p.lru.PushFront(&openPartition{dir: dir, part: part})
+
+	return part, nil
+}
+
+// finish closes the file of an open partition and removes it from the LRU.
+func (p *partitioner) finish(elem *list.Element) error {
+	current := p.lru.Remove(elem).(*openPartition)
+	delete(p.files, current.dir)
+
+	if err := current.part.close(); err != nil {
+		return err
+	}
+	p.parts = append(p.parts, current.part.splitPart)
+	return nil
+}
+
+// closeAll finishes all open partitions and returns the first error.
+func (p *partitioner) closeAll() error {
+	var err error
+	for p.lru.Len() > 0 {
+		if cerr := p.finish(p.lru.Back()); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// abortAll discards the files of all open partitions after a failed write.
+func (p *partitioner) abortAll() {
+	for p.lru.Len() > 0 {
+		current := p.lru.Remove(p.lru.Back()).(*openPartition)
+		delete(p.files, current.dir)
+		current.part.abort()
+	}
+}
+
+func (p *partitioner) writtenParts() []splitPart {
+	return p.parts
+}
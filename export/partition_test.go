@@ -0,0 +1,81 @@
+package export
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/cheggaaa/pb.v2"
+
+	elasticsearch "github.com/pteich/elastic-query-export/elastic"
+	"github.com/pteich/elastic-query-export/flags"
+	"github.com/pteich/elastic-query-export/formats"
+)
+
+func Test_partitioner(t *testing.T) {
+	dir := t.TempDir()
+	bar := pb.New(4)
+
+	keys, err := formats.ParsePartitionKeys("@timestamp:day,host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns, err := formats.ParseColumns("host,n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &partitioner{
+		root:    dir,
+		pattern: "part.csv",
+		keys:    keys,
+		maxOpen: 1,
+		open: func(path string) (*outputFile, error) {
+			return openOutput(path, flags.CompressNone, 0, nil, false)
+		},
+		formatter: func(w io.Writer, _ string) (Formatter, error) {
+			return formats.CSV{Outfile: w, ProgessBar: bar, Columns: columns, Workers: 1}, nil
+		},
+		warnings: &formats.Warnings{Logger: log.New(io.Discard, "", 0)},
+	}
+
+	hits := make(chan elasticsearch.SearchHit, 5)
+	hits <- testHit{source: []byte(`{"@timestamp":"2026-10-17T10:00:00Z","host":"web1","n":1}`)}
+	hits <- testHit{source: []byte(`{invalid`)}
+	hits <- testHit{source: []byte(`{"@timestamp":"2026-10-17T11:00:00Z","host":"web1","n":2}`)}
+	hits <- testHit{source: []byte(`{"@timestamp":"2026-10-17T12:00:00Z","host":"web2","n":3}`)}
+	hits <- testHit{source: []byte(`{"@timestamp":"2026-10-17T13:00:00Z","host":"web1","n":4}`)}
+	close(hits)
+
+	if err := p.Run(context.Background(), hits); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// invalid documents are skipped like in unpartitioned exports
+	if skipped := p.warnings.Skipped(); skipped != 1 {
+		t.Errorf("expected 1 skipped document, got %d", skipped)
+	}
+
+	// only one file may be open, so web1 is finished when web2 starts and continues with a new part
+	want := map[string]string{
+		filepath.Join(dir, "day=2026-10-17", "host=web1", "part-0000.csv"): "host,n\nweb1,1\nweb1,2\n",
+		filepath.Join(dir, "day=2026-10-17", "host=web2", "part-0000.csv"): "host,n\nweb2,3\n",
+		filepath.Join(dir, "day=2026-10-17", "host=web1", "part-0001.csv"): "host,n\nweb1,4\n",
+	}
+
+	if len(p.parts) != len(want) {
+		t.Fatalf("expected %d parts, got %v", len(want), p.parts)
+	}
+	for _, part := range p.parts {
+		content, err := os.ReadFile(part.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != want[part.path] {
+			t.Errorf("unexpected content of %s: %q", part.path, content)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/flags"
+)
+
+// progressMode returns the progress mode set with --progress. Without it the progress bar is only
+// drawn if stderr is a terminal.
+func progressMode(conf *flags.Flags) (string, error) {
+	switch conf.Progress {
+	case "":
+		if info, err := os.Stderr.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			return flags.ProgressBar, nil
+		}
+		return flags.ProgressNone, nil
+	case flags.ProgressBar, flags.ProgressJSON, flags.ProgressNone:
+		return conf.Progress, nil
+	default:
+		return "", fmt.Errorf("unknown progress mode %s", conf.Progress)
+	}
+}
+
+// progress collects the state of a running export. The documents are counted by the progress bar,
+// which is only drawn in bar mode, the bytes by the output files. Events are passed to callback
+// if it is set, instead of being written to w.
+type progress struct {
+	w        io.Writer
+	callback func(ProgressEvent)
+	mode     string
+	// summaryOnSignal prints a summary on SIGUSR1
+	summaryOnSignal bool
+	bar             *pb.ProgressBar
+	outputs         *outputRecorder
+	started         time.Time
+	// pageLatency is the duration of the last scroll request
+	pageLatency atomic.Int64
+	// retries counts the retried requests of the sinks
+	retries atomic.Int64
+	mu      sync.Mutex
+}
+
+// ProgressEvent is the state of a running export, written as JSON line for --progress json.
+type ProgressEvent struct {
+	Event         string    `json:"event"`
+	Time          time.Time `json:"time"`
+	Docs          int64     `json:"docs"`
+	Total         int64     `json:"total"`
+	DocsPerSecond float64   `json:"docs_per_second"`
+	Bytes         int64     `json:"bytes"`
+	ETASeconds    float64   `json:"eta_seconds"`
+	PageLatencyMS float64   `json:"page_latency_ms"`
+	Retries       int64     `json:"retries"`
+}
+
+// newProgress starts the progress bar for total documents, it is only drawn in bar mode.
+func newProgress(w io.Writer, mode string, total int64, outputs *outputRecorder) *progress {
+	bar := pb.New64(total)
+	if mode != flags.ProgressBar {
+		bar.Set(pb.Static, true)
+	}
+
+	return &progress{
+		w:       w,
+		mode:    mode,
+		bar:     bar.Start(),
+		outputs: outputs,
+		started: time.Now(),
+	}
+}
+
+func (p *progress) event(name string, now time.Time) ProgressEvent {
+	e := ProgressEvent{
+		Event:         name,
+		Time:          now,
+		Docs:          p.bar.Current(),
+		Total:         p.bar.Total(),
+		Bytes:         p.outputs.written(),
+		PageLatencyMS: float64(p.pageLatency.Load()) / float64(time.Millisecond),
+		Retries:       p.retries.Load(),
+	}
+
+	if elapsed := now.Sub(p.started).Seconds(); elapsed > 0 {
+		e.DocsPerSecond = float64(e.Docs) / elapsed
+	}
+	if e.DocsPerSecond > 0 && e.Total > e.Docs {
+		e.ETASeconds = float64(e.Total-e.Docs) / e.DocsPerSecond
+	}
+
+	return e
+}
+
+// report writes an event as JSON line or passes it to the callback.
+func (p *progress) report(name string) {
+	e := p.event(name, time.Now())
+	if p.callback != nil {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.callback(e)
+		return
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.w.Write(append(data, '\n'))
+}
+
+// summary writes the current state, as JSON event in json mode and as text line otherwise.
+func (p *progress) summary() {
+	if p.mode == flags.ProgressJSON {
+		p.report("summary")
+		return
+	}
+
+	e := p.event("summary", time.Now())
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintf(p.w, "\nExported %d of %d documents, %.0f docs/s, %d bytes written, ETA %s, page latency %.0fms, %d retries\n",
+		e.Docs, e.Total, e.DocsPerSecond, e.Bytes, time.Duration(e.ETASeconds*float64(time.Second)).Round(time.Second), e.PageLatencyMS, e.Retries)
+}
+
+// run writes progress events every interval in json mode and a summary on SIGUSR1 until ctx is done.
+func (p *progress) run(ctx context.Context, interval time.Duration) {
+	signals := make(chan os.Signal, 1)
+	if p.summaryOnSignal {
+		notifySummary(signals)
+		defer stopSummary(signals)
+	}
+
+	var tick <-chan time.Time
+	if p.mode == flags.ProgressJSON {
+		if interval <= 0 {
+			interval = 5 * time.Second
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+			p.report("progress")
+		case <-signals:
+			p.summary()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// finish stops the progress bar and writes the final event in json mode.
+func (p *progress) finish() {
+	p.bar.Finish()
+	if p.mode == flags.ProgressJSON {
+		p.report("done")
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package export
+
+import "os"
+
+// notifySummary does nothing, since there is no SIGUSR1 on this platform.
+func notifySummary(c chan<- os.Signal) {}
+
+func stopSummary(c chan<- os.Signal) {}
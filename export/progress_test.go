@@ -0,0 +1,46 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pteich/elastic-query-export/flags"
+)
+
+func Test_progressMode(t *testing.T) {
+	for _, mode := range []string{flags.ProgressBar, flags.ProgressJSON, flags.ProgressNone} {
+		got, err := progressMode(&flags.Flags{Progress: mode})
+		if err != nil || got != mode {
+			t.Errorf("progressMode(%s) = %v, %v", mode, got, err)
+		}
+	}
+
+	if _, err := progressMode(&flags.Flags{Progress: "fancy"}); err == nil {
+		t.Error("expected error for unknown progress mode")
+	}
+}
+
+func Test_progress(t *testing.T) {
+	var buf bytes.Buffer
+	p := newProgress(&buf, flags.ProgressJSON, 100, &outputRecorder{})
+	p.bar.Add(25)
+	p.pageLatency.Store(int64(150 * time.Millisecond))
+	p.retries.Add(2)
+
+	e := p.event("progress", p.started.Add(5*time.Second))
+	if e.Docs != 25 || e.Total != 100 || e.DocsPerSecond != 5 || e.ETASeconds != 15 || e.PageLatencyMS != 150 || e.Retries != 2 {
+		t.Errorf("unexpected event %+v", e)
+	}
+
+	p.finish()
+
+	var done ProgressEvent
+	if err := json.Unmarshal(buf.Bytes(), &done); err != nil {
+		t.Fatalf("invalid event %q: %v", buf.String(), err)
+	}
+	if done.Event != "done" || done.Docs != 25 {
+		t.Errorf("unexpected final event %+v", done)
+	}
+}
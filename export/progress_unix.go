@@ -0,0 +1,18 @@
+//go:build unix
+
+package export
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifySummary sends SIGUSR1 to c to print a progress summary.
+func notifySummary(c chan<- os.Signal) {
+	signal.Notify(c, syscall.SIGUSR1)
+}
+
+func stopSummary(c chan<- os.Signal) {
+	signal.Stop(c)
+}
@@ -0,0 +1,124 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// parseRuntimeFields parses runtime field definitions in the form name:type=painless-script,
+// one definition per line, into the runtime_mappings of a search request.
+func parseRuntimeFields(definitions string) (map[string]interface{}, error) {
+	mappings := make(map[string]interface{})
+
+	for _, line := range strings.Split(definitions, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		definition, script, found := strings.Cut(line, "=")
+		if !found || strings.TrimSpace(script) == "" {
+			return nil, fmt.Errorf("invalid runtime field %q, expected name:type=script", line)
+		}
+
+		name, fieldType, found := strings.Cut(definition, ":")
+		name = strings.TrimSpace(name)
+		fieldType = strings.TrimSpace(fieldType)
+		if !found || name == "" || fieldType == "" {
+			return nil, fmt.Errorf("invalid runtime field %q, expected name:type=script", line)
+		}
+
+		mappings[name] = map[string]interface{}{
+			"type": fieldType,
+			"script": map[string]interface{}{
+				"source": strings.TrimSpace(script),
+			},
+		}
+	}
+
+	return mappings, nil
+}
+
+// runtimeFieldSeparator matches a semicolon that starts the next definition name:type= of a list. Scripts
+// contain semicolons and commas themselves, so only separators followed by a definition split the list.
+var runtimeFieldSeparator = regexp.MustCompile(`;\s*[\w.@-]+\s*:\s*\w+\s*=`)
+
+// splitRuntimeFields splits a semicolon separated list of runtime field definitions into lines.
+func splitRuntimeFields(definitions string) string {
+	var lines []string
+
+	for _, line := range strings.Split(definitions, "\n") {
+		start := 0
+		for _, match := range runtimeFieldSeparator.FindAllStringIndex(line, -1) {
+			lines = append(lines, line[start:match[0]])
+			start = match[0] + 1
+		}
+		lines = append(lines, line[start:])
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// runtimeFields collects the runtime field definitions from the command line and the runtime fields file.
+func runtimeFields(definition, file string) (map[string]interface{}, error) {
+	definitions := splitRuntimeFields(definition)
+
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		definitions += "\n" + string(data)
+	}
+
+	mappings, err := parseRuntimeFields(definitions)
+	if err != nil || len(mappings) == 0 {
+		return nil, err
+	}
+
+	return mappings, nil
+}
+
+func runtimeFieldNames(mappings map[string]interface{}) []string {
+	names := make([]string, 0, len(mappings))
+	for name := range mappings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type sortField struct {
+	field     string
+	ascending bool
+}
+
+// parseSort parses a comma separated list of fields with an optional :asc or :desc suffix.
+func parseSort(sortList string) []sortField {
+	var fields []sortField
+
+	for _, field := range strings.Split(sortList, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		ascending := true
+		if i := strings.LastIndex(field, ":"); i > 0 {
+			switch strings.ToLower(field[i+1:]) {
+			case "desc":
+				ascending = false
+				field = field[:i]
+			case "asc":
+				field = field[:i]
+			}
+		}
+
+		fields = append(fields, sortField{field: field, ascending: ascending})
+	}
+
+	return fields
+}
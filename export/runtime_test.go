@@ -0,0 +1,68 @@
+package export
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseRuntimeFields(t *testing.T) {
+	mappings, err := parseRuntimeFields("duration_s:double=emit(doc['duration'].value / 1000.0)\n\n# comment\nurl_path:keyword=emit(doc['url'].value.splitOnToken('?')[0])")
+	if err != nil {
+		t.Fatalf("parseRuntimeFields() error = %v", err)
+	}
+
+	want := map[string]interface{}{
+		"duration_s": map[string]interface{}{
+			"type":   "double",
+			"script": map[string]interface{}{"source": "emit(doc['duration'].value / 1000.0)"},
+		},
+		"url_path": map[string]interface{}{
+			"type":   "keyword",
+			"script": map[string]interface{}{"source": "emit(doc['url'].value.splitOnToken('?')[0])"},
+		},
+	}
+	if !reflect.DeepEqual(mappings, want) {
+		t.Errorf("parseRuntimeFields() = %v, want %v", mappings, want)
+	}
+
+	for _, invalid := range []string{"name=emit(1)", "name:long", ":long=emit(1)"} {
+		if _, err := parseRuntimeFields(invalid); err == nil {
+			t.Errorf("parseRuntimeFields(%q) expected error", invalid)
+		}
+	}
+}
+
+func Test_runtimeFields(t *testing.T) {
+	mappings, err := runtimeFields("a:long=emit(1); b:keyword=if (doc['x'].size() > 0) { emit(doc['x'].value); } ;c.d:double=emit(2.0)", "")
+	if err != nil {
+		t.Fatalf("runtimeFields() error = %v", err)
+	}
+
+	want := map[string]string{
+		"a":   "emit(1)",
+		"b":   "if (doc['x'].size() > 0) { emit(doc['x'].value); }",
+		"c.d": "emit(2.0)",
+	}
+	if len(mappings) != len(want) {
+		t.Fatalf("runtimeFields() = %v, want fields %v", mappings, want)
+	}
+	for name, source := range want {
+		mapping, _ := mappings[name].(map[string]interface{})
+		script, _ := mapping["script"].(map[string]interface{})
+		if script["source"] != source {
+			t.Errorf("runtime field %s has script %v, want %q", name, script["source"], source)
+		}
+	}
+}
+
+func Test_parseSort(t *testing.T) {
+	got := parseSort("@timestamp:desc, host , duration_s:asc")
+	want := []sortField{
+		{field: "@timestamp", ascending: false},
+		{field: "host", ascending: true},
+		{field: "duration_s", ascending: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseSort() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,160 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+
+	"github.com/pteich/elastic-query-export/flags"
+)
+
+const s3Scheme = "s3://"
+
+var errUploadAborted = errors.New("upload aborted")
+
+// s3Output uploads output files given as s3://bucket/key URLs to S3 compatible object storage.
+type s3Output struct {
+	client  *minio.Client
+	options minio.PutObjectOptions
+}
+
+// s3Upload streams everything written to it as multipart upload. The upload is completed by
+// Close and aborted by abort, so a failed export leaves no partial object behind.
+type s3Upload struct {
+	pw   *io.PipeWriter
+	done chan error
+	once sync.Once
+	err  error
+}
+
+func isS3(path string) bool {
+	return strings.HasPrefix(path, s3Scheme)
+}
+
+// parseS3URL returns the bucket and key of an s3://bucket/key URL.
+func parseS3URL(raw string) (string, string, error) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(raw, s3Scheme), "/")
+	if !isS3(raw) || bucket == "" || key == "" {
+		return "", "", fmt.Errorf("invalid S3 URL %s, use s3://bucket/key", raw)
+	}
+	return bucket, key, nil
+}
+
+// splitOutput splits a local path or S3 URL into its directory and file name.
+func splitOutput(p string) (string, string) {
+	if isS3(p) {
+		i := strings.LastIndex(p, "/")
+		return p[:i], p[i+1:]
+	}
+	return filepath.Dir(p), filepath.Base(p)
+}
+
+// joinOutput joins the slash separated elements to a local directory or S3 URL.
+func joinOutput(root string, elem ...string) string {
+	if isS3(root) {
+		return strings.TrimSuffix(root, "/") + "/" + path.Join(elem...)
+	}
+	return filepath.Join(root, filepath.FromSlash(path.Join(elem...)))
+}
+
+// newS3Output creates the S3 client. Credentials are taken from the AWS environment variables,
+// the AWS credentials file or the IAM role of the machine.
+func newS3Output(conf *flags.Flags) (*s3Output, error) {
+	endpoint, secure := "s3.amazonaws.com", true
+	if conf.S3Endpoint != "" {
+		u, err := url.Parse(conf.S3Endpoint)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid S3 endpoint %s, use a URL like http://localhost:9000", conf.S3Endpoint)
+		}
+		endpoint, secure = u.Host, u.Scheme != "http"
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds: credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.FileAWSCredentials{},
+			&credentials.IAM{},
+		}),
+		Secure: secure,
+		Region: conf.S3Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	partSize, err := parseByteSize(conf.S3PartSize)
+	if err != nil {
+		return nil, err
+	}
+
+	options := minio.PutObjectOptions{PartSize: uint64(partSize)}
+	switch conf.S3SSE {
+	case "":
+	case "AES256":
+		options.ServerSideEncryption = encrypt.NewSSE()
+	case "aws:kms":
+		options.ServerSideEncryption, err = encrypt.NewSSEKMS(conf.S3SSEKMSKeyID, nil)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown server-side encryption %s, use AES256 or aws:kms", conf.S3SSE)
+	}
+
+	return &s3Output{client: client, options: options}, nil
+}
+
+// open starts the upload to the S3 URL and wraps it with the given compression and encryption.
+func (s *s3Output) open(ctx context.Context, target, codec string, level int, recipients []age.Recipient) (*outputFile, error) {
+	bucket, key, err := parseS3URL(target)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	upload := &s3Upload{pw: pw, done: make(chan error, 1)}
+
+	go func() {
+		// a canceled export is aborted by the writer, the upload itself needs the context to abort it
+		_, err := s.client.PutObject(context.WithoutCancel(ctx), bucket, key, pr, -1, s.options)
+		if err != nil {
+			err = fmt.Errorf("uploading %s - %w", target, err)
+		}
+		pr.CloseWithError(err)
+		upload.done <- err
+	}()
+
+	return newOutputFile(upload, upload, upload.abort, codec, level, recipients)
+}
+
+func (u *s3Upload) Write(p []byte) (int, error) {
+	return u.pw.Write(p)
+}
+
+// Close completes the upload and returns its error.
+func (u *s3Upload) Close() error {
+	return u.finish(nil)
+}
+
+func (u *s3Upload) abort() {
+	u.finish(errUploadAborted)
+}
+
+func (u *s3Upload) finish(err error) error {
+	u.once.Do(func() {
+		u.pw.CloseWithError(err)
+		u.err = <-u.done
+	})
+	return u.err
+}
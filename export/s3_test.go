@@ -0,0 +1,125 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/pteich/elastic-query-export/flags"
+)
+
+// fakeS3 is a minimal S3 multipart upload API that stores completed objects.
+type fakeS3 struct {
+	mu      sync.Mutex
+	parts   map[string][][]byte
+	objects map[string][]byte
+	aborted int
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.parts[r.URL.Path] = nil
+		fmt.Fprint(w, `<InitiateMultipartUploadResult><UploadId>1</UploadId></InitiateMultipartUploadResult>`)
+	case r.Method == http.MethodPut && query.Has("partNumber"):
+		data, _ := io.ReadAll(r.Body)
+		f.parts[r.URL.Path] = append(f.parts[r.URL.Path], data)
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, len(f.parts[r.URL.Path])))
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		f.objects[r.URL.Path] = bytes.Join(f.parts[r.URL.Path], nil)
+		fmt.Fprint(w, `<CompleteMultipartUploadResult><Bucket>bucket</Bucket><ETag>"1"</ETag></CompleteMultipartUploadResult>`)
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		f.aborted++
+		delete(f.parts, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *s3Output) {
+	fake := &fakeS3{parts: map[string][][]byte{}, objects: map[string][]byte{}}
+	server := httptest.NewTLSServer(fake)
+	t.Cleanup(server.Close)
+
+	client, err := minio.New(strings.TrimPrefix(server.URL, "https://"), &minio.Options{
+		Creds:     credentials.NewStaticV4("key", "secret", ""),
+		Secure:    true,
+		Region:    "us-east-1",
+		Transport: server.Client().Transport,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fake, &s3Output{client: client}
+}
+
+func Test_s3Output(t *testing.T) {
+	fake, s3 := newFakeS3(t)
+
+	out, err := s3.open(context.Background(), "s3://bucket/exports/output.csv", flags.CompressNone, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(out, "a,b\n1,2\n")
+	if err := out.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if got := string(fake.objects["/bucket/exports/output.csv"]); got != "a,b\n1,2\n" {
+		t.Errorf("unexpected object %q", got)
+	}
+}
+
+func Test_s3OutputAbort(t *testing.T) {
+	fake, s3 := newFakeS3(t)
+
+	out, err := s3.open(context.Background(), "s3://bucket/output.csv", flags.CompressNone, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(out, "a,b\n")
+	out.Abort()
+	if err := out.Close(); !errors.Is(err, errUploadAborted) {
+		t.Errorf("Close() error = %v, want %v", err, errUploadAborted)
+	}
+
+	if len(fake.objects) != 0 || fake.aborted != 1 {
+		t.Errorf("expected aborted upload, got objects %v and %d aborts", fake.objects, fake.aborted)
+	}
+}
+
+func Test_parseS3URL(t *testing.T) {
+	bucket, key, err := parseS3URL("s3://bucket/exports/day=2026-10-17/part-0000.csv")
+	if err != nil || bucket != "bucket" || key != "exports/day=2026-10-17/part-0000.csv" {
+		t.Errorf("parseS3URL() = %s, %s, %v", bucket, key, err)
+	}
+
+	for _, url := range []string{"s3://bucket", "s3:///key", "bucket/key"} {
+		if _, _, err := parseS3URL(url); err == nil {
+			t.Errorf("expected error for %s", url)
+		}
+	}
+}
+
+func Test_joinOutput(t *testing.T) {
+	root, pattern := splitOutput("s3://bucket/lake/part.csv")
+	if got := joinOutput(root, "day=2026-10-17", pattern); got != "s3://bucket/lake/day=2026-10-17/part.csv" {
+		t.Errorf("joinOutput() = %s", got)
+	}
+}
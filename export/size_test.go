@@ -0,0 +1,32 @@
+package export
+
+import "testing"
+
+func Test_parseByteSize(t *testing.T) {
+	tests := []struct {
+		size    string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"500", 500, false},
+		{"500b", 500, false},
+		{"64kb", 64 << 10, false},
+		{"90MB", 90 << 20, false},
+		{"1g", 1 << 30, false},
+		{"ten", 0, true},
+		{"-1mb", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.size, func(t *testing.T) {
+			got, err := parseByteSize(tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseByteSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseByteSize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	elasticsearch "github.com/pteich/elastic-query-export/elastic"
+	"github.com/pteich/elastic-query-export/formats"
+)
+
+var partPlaceholder = regexp.MustCompile(`\{n(?::(\d+))?\}`)
+
+// splitter is a Formatter that writes the hits into several parts. Every part is a complete file
+// written by its own formatter, so CSV parts have a header and JSON arrays are closed. A new part is
+// started when the current one has rows documents or at least bytes bytes written to the file. Since
+// formatters and compressors buffer data, parts can be a bit larger than bytes.
+type splitter struct {
+	pattern   string
+	rows      int
+	bytes     int64
+	open      func(path string) (*outputFile, error)
+	formatter func(w io.Writer, path string) (Formatter, error)
+	parts     []splitPart
+}
+
+// splitPart is a written part of a split output.
+type splitPart struct {
+	path  string
+	rows  int
+	bytes int64
+}
+
+// partWriter runs a formatter for a single part that gets its hits by write.
+type partWriter struct {
+	splitPart
+	out  *outputFile
+	hits chan elasticsearch.SearchHit
+	done chan error
+	err  error
+}
+
+func (s *splitter) Run(ctx context.Context, hits <-chan elasticsearch.SearchHit) error {
+	var part *partWriter
+	var err error
+	n := 0
+
+	for hit := range hits {
+		if part != nil && part.full(s.rows, s.bytes) {
+			if err := s.finish(part); err != nil {
+				return err
+			}
+			part = nil
+		}
+
+		if part == nil {
+			part, err = startPart(ctx, partName(s.pattern, n), s.open, s.formatter)
+			if err != nil {
+				return err
+			}
+			n++
+		}
+
+		if err := part.write(hit); err != nil {
+			part.abort()
+			return err
+		}
+	}
+
+	if err := context.Cause(ctx); err != nil {
+		if part != nil {
+			part.abort()
+		}
+		return err
+	}
+
+	// an export without documents still gets one, possibly empty, part
+	if part == nil {
+		part, err = startPart(ctx, partName(s.pattern, n), s.open, s.formatter)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.finish(part)
+}
+
+func (s *splitter) finish(part *partWriter) error {
+	if err := part.close(); err != nil {
+		return err
+	}
+	s.parts = append(s.parts, part.splitPart)
+	return nil
+}
+
+func (s *splitter) writtenParts() []splitPart {
+	return s.parts
+}
+
+// startPart opens the file at path and starts a formatter writing to it.
+func startPart(ctx context.Context, path string, open func(path string) (*outputFile, error), formatter func(w io.Writer, path string) (Formatter, error)) (*partWriter, error) {
+	out, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := formatter(out, path)
+	if err != nil {
+		out.Close()
+		return nil, err
+	}
+
+	part := &partWriter{
+		splitPart: splitPart{path: path},
+		out:       out,
+		hits:      make(chan elasticsearch.SearchHit),
+		done:      make(chan error, 1),
+	}
+	go func() {
+		part.done <- f.Run(ctx, part.hits)
+	}()
+
+	return part, nil
+}
+
+func (p *partWriter) write(hit elasticsearch.SearchHit) error {
+	select {
+	case p.hits <- hit:
+		p.rows++
+		return nil
+	case err := <-p.done:
+		// the formatter stopped early
+		if err == nil {
+			err = fmt.Errorf("writing %s stopped early", p.path)
+		}
+		p.err = err
+		return err
+	}
+}
+
+// full reports whether the part reached one of the given limits, a limit of 0 is ignored.
+func (p *partWriter) full(rows int, bytes int64) bool {
+	return (rows > 0 && p.rows >= rows) || (bytes > 0 && p.out.Written() >= bytes)
+}
+
+// close waits until the formatter has written all hits and closes the file.
+func (p *partWriter) close() error {
+	close(p.hits)
+
+	err := p.err
+	if err == nil {
+		err = <-p.done
+	}
+	if cerr := p.out.Close(); err == nil {
+		err = cerr
+	}
+	p.bytes = p.out.Written()
+
+	return err
+}
+
+// abort discards the part after a failed write, if the output supports it, and closes it.
+func (p *partWriter) abort() {
+	close(p.hits)
+	p.out.Abort()
+	if p.err == nil {
+		<-p.done
+	}
+	p.out.Close()
+}
+
+// partName replaces the {n} or {n:04} placeholder of the pattern with the part number. Without
+// placeholder the number is added in front of the file extension, e.g. export-0001.csv.gz.
+func partName(pattern string, n int) string {
+	if !partPlaceholder.MatchString(pattern) {
+		ext := formats.FileExt(pattern)
+		pattern = strings.TrimSuffix(pattern, ext) + "-{n:04}" + ext
+	}
+
+	return partPlaceholder.ReplaceAllStringFunc(pattern, func(placeholder string) string {
+		width := 0
+		if match := partPlaceholder.FindStringSubmatch(placeholder); match[1] != "" {
+			width, _ = strconv.Atoi(match[1])
+		}
+		return fmt.Sprintf("%0*d", width, n)
+	})
+}
+
+// printParts prints the path, number of documents and size of all written parts.
+func printParts(w io.Writer, parts []splitPart) {
+	fmt.Fprintf(w, "Written %d parts:\n", len(parts))
+	for _, part := range parts {
+		fmt.Fprintf(w, "  %s\t%d documents\t%d bytes\n", part.path, part.rows, part.bytes)
+	}
+}
@@ -0,0 +1,107 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/pgzip"
+	"gopkg.in/cheggaaa/pb.v2"
+
+	elasticsearch "github.com/pteich/elastic-query-export/elastic"
+	"github.com/pteich/elastic-query-export/flags"
+	"github.com/pteich/elastic-query-export/formats"
+)
+
+type testHit struct {
+	source []byte
+	fields []byte
+}
+
+func (h testHit) GetSource() []byte  { return h.source }
+func (h testHit) GetFields() []byte  { return h.fields }
+func (h testHit) GetID() string      { return "" }
+func (h testHit) GetIndex() string   { return "" }
+func (h testHit) GetRouting() string { return "" }
+
+func Test_splitter(t *testing.T) {
+	dir := t.TempDir()
+	bar := pb.New(5)
+
+	s := &splitter{
+		pattern: filepath.Join(dir, "export-{n:02}.json.gz"),
+		rows:    2,
+		open: func(path string) (*outputFile, error) {
+			return openOutput(path, flags.CompressGzip, 0, nil, false)
+		},
+		formatter: func(w io.Writer, _ string) (Formatter, error) {
+			return formats.JSON{Outfile: w, ProgessBar: bar, Style: formats.JSONStyleArray}, nil
+		},
+	}
+
+	hits := make(chan elasticsearch.SearchHit, 5)
+	for i := 1; i <= 5; i++ {
+		hits <- testHit{source: []byte(fmt.Sprintf(`{"n":%d}`, i))}
+	}
+	close(hits)
+
+	if err := s.Run(context.Background(), hits); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(s.parts) != 3 {
+		t.Fatalf("expected 3 parts, got %v", s.parts)
+	}
+
+	for i, part := range s.parts {
+		if want := filepath.Join(dir, fmt.Sprintf("export-%02d.json.gz", i)); part.path != want {
+			t.Errorf("part %d written to %s, want %s", i, part.path, want)
+		}
+
+		file, err := os.Open(part.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := pgzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var documents []map[string]int
+		if err := json.NewDecoder(r).Decode(&documents); err != nil {
+			t.Errorf("part %d is no valid JSON array: %v", i, err)
+		}
+		file.Close()
+
+		if len(documents) != part.rows || documents[0]["n"] != i*2+1 {
+			t.Errorf("unexpected documents %v in part %d", documents, i)
+		}
+	}
+}
+
+func Test_partName(t *testing.T) {
+	tests := []struct {
+		pattern string
+		n       int
+		want    string
+	}{
+		{"export-{n:04}.csv", 3, "export-0003.csv"},
+		{"export-{n}.csv", 12, "export-12.csv"},
+		{"export.csv", 1, "export-0001.csv"},
+		{"export.csv.gz", 1, "export-0001.csv.gz"},
+		{"export.csv.zst.age", 1, "export-0001.csv.zst.age"},
+		{"export", 1, "export-0001"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			if got := partName(tt.pattern, tt.n); got != tt.want {
+				t.Errorf("partName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
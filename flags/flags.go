@@ -1,9 +1,41 @@
 package flags
 
+import "strings"
+
+const (
+	FormatCSV      = "csv"
+	FormatJSON     = "json"
+	FormatRAW      = "raw"
+	FormatParquet  = "parquet"
+	FormatArrow    = "arrow"
+	FormatAvro     = "avro"
+	FormatXLSX     = "xlsx"
+	FormatSQLite   = "sqlite"
+	FormatBulk     = "bulk"
+	FormatTemplate = "template"
+)
+
+const (
+	CompressNone = "none"
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+)
+
+const (
+	ProgressBar  = "bar"
+	ProgressJSON = "json"
+	ProgressNone = "none"
+)
+
+const (
+	SinkFile          = "file"
+	SinkElasticsearch = "elasticsearch"
+	SinkHTTP          = "http"
+)
+
 const (
-	FormatCSV  = "csv"
-	FormatJSON = "json"
-	FormatRAW  = "raw"
+	ArrowFormatFile   = "file"
+	ArrowFormatStream = "stream"
 )
 
 type Flags struct {
@@ -17,13 +49,140 @@ type Flags struct {
 	Index            string `cli:"index" cliAlt:"i" usage:"ElasticSearch Index (or Index Prefix)"`
 	RAWQuery         string `cli:"rawquery" cliAlt:"r" usage:"ElasticSearch raw query string"`
 	Query            string `cli:"query" cliAlt:"q" usage:"Lucene query same that is used in Kibana search input"`
-	OutFormat        string `cli:"outformat" cliAlt:"f" usage:"Format of the output data. [json|csv]"`
+	OutFormat        string `cli:"outformat" cliAlt:"f" usage:"Format of the output data. [json|csv|raw|parquet|arrow|avro|xlsx|sqlite|bulk|template]"`
+	JSONStyle        string `cli:"json-style" usage:"Style of JSON output. [ndjson|array|pretty]"`
 	Outfile          string `cli:"outfile" cliAlt:"o" usage:"Path to output file"`
+	Compress         string `cli:"compress" usage:"Compress the output file, detected from the file extension if not set. [none|gzip|zstd]"`
+	CompressLevel    int    `cli:"compress-level" usage:"Compression level, 0 uses the default level of the codec"`
+	EncryptTo        string `cli:"encrypt-to" usage:"Encrypt the output with age to these recipients as comma separated list or repeated flag, e.g. age1..."`
+	EncryptToFile    string `cli:"encrypt-to-file" usage:"Path to a file with age recipients to encrypt the output to, one per line"`
+	S3Endpoint       string `cli:"s3-endpoint" usage:"Endpoint URL of S3 compatible storage like MinIO or Ceph for s3:// output files, default is AWS"`
+	S3Region         string `cli:"s3-region" usage:"Region of the S3 bucket"`
+	S3SSE            string `cli:"s3-sse" usage:"Server-side encryption of S3 uploads. [AES256|aws:kms]"`
+	S3SSEKMSKeyID    string `cli:"s3-sse-kms-key-id" usage:"KMS key ID used for aws:kms server-side encryption"`
+	S3PartSize       string `cli:"s3-part-size" usage:"Size of the parts of S3 multipart uploads, e.g. 16mb"`
+	KeepPartial      bool   `cli:"keep-partial" usage:"Keep the output of failed exports as .partial file instead of removing it"`
+	SplitRows        int    `cli:"split-rows" usage:"Start a new output file after this number of documents"`
+	SplitBytes       string `cli:"split-bytes" usage:"Start a new output file after this size is reached, e.g. 500mb"`
+	PartitionBy      string `cli:"partition-by" usage:"Write documents into Hive-style directories by these fields as comma separated list, dates with granularity like @timestamp:day"`
+	MaxOpenFiles     int    `cli:"max-open-files" usage:"Maximum number of partition files that are open at the same time"`
 	StartDate        string `cli:"start" cliAlt:"s" usage:"Start date for included documents"`
 	EndDate          string `cli:"end" cliAlt:"e" usage:"End date for included documents"`
 	ScrollSize       int    `cli:"size" usage:"Number of documents that will be returned per shard"`
 	Timefield        string `cli:"timefield" usage:"Field name to use for start and end date query"`
-	Fieldlist        string `cli:"fields" usage:"Fields to include in export as comma separated list"`
+	Fieldlist        string `cli:"fields" usage:"Fields to include in export as comma separated list, supports wildcards (user.*), renames (name=field), _id, _index, 'constants' and concat(), date(), len()"`
+	FieldsFile       string `cli:"fields-file" usage:"Path to a file with the --fields column spec, one or more columns per line"`
+	ExcludeFieldlist string `cli:"exclude-fields" usage:"Fields to exclude from export as comma separated list, wildcards like user.* are supported"`
+	Sort             string `cli:"sort" usage:"Sort by comma separated list of fields, append :desc for descending order"`
+	RuntimeField     string `cli:"runtime-field" usage:"Runtime field definitions name:type=painless-script, several separated by semicolon"`
+	RuntimeFieldFile string `cli:"runtime-fields-file" usage:"Path to a file with runtime field definitions, one per line"`
+	FetchMode        string `cli:"fetch-mode" usage:"Where to take field values from. [source|fields|docvalues|stored]"`
+	RowGroupSize     int    `cli:"parquet-row-group-size" usage:"Number of documents per Parquet row group"`
+	ParquetCodec     string `cli:"parquet-compression" usage:"Compression of Parquet files. [snappy|zstd|gzip|none]"`
+	ArrowFormat      string `cli:"arrow-format" usage:"Arrow IPC format to write. [file|stream]"`
+	AvroCodec        string `cli:"avro-codec" usage:"Compression codec of Avro blocks. [deflate|snappy|null]"`
+	AvroSchema       bool   `cli:"avro-schema" usage:"Only write the Avro schema generated from the index mapping"`
+	ArrayFields      string `cli:"array-fields" usage:"Fields that hold arrays as comma separated list, they are written as lists to parquet, arrow and avro, wildcards are supported"`
+	SQLiteIndex      string `cli:"sqlite-index" usage:"Comma separated list of columns to create SQLite indexes on"`
+	Append           bool   `cli:"append" usage:"Append to an existing SQLite table with a compatible schema"`
+	BulkIndex        string `cli:"bulk-index" usage:"Target index name written to the bulk action lines instead of the source index"`
+	BulkOp           string `cli:"bulk-op" usage:"Op type of the bulk action lines. [index|create]"`
+	BulkDropID       bool   `cli:"bulk-drop-id" usage:"Do not write document ids to the bulk action lines"`
+	BulkMaxSize      string `cli:"bulk-max-size" usage:"Start a new bulk file before this size is exceeded, e.g. 90mb"`
+	TemplateFile     string `cli:"template-file" usage:"Path to a Go text/template that is rendered for every document"`
+	TemplateHeader   string `cli:"template-header" usage:"Template rendered once before the first document"`
+	TemplateFooter   string `cli:"template-footer" usage:"Template rendered once after the last document"`
+	Sink             string `cli:"sink" usage:"Where to send the exported documents. [file|elasticsearch|http]"`
+	TargetURL        string `cli:"target-url" usage:"URL of the cluster the elasticsearch sink writes to"`
+	TargetBatchSize  int    `cli:"target-batch-size" usage:"Number of documents per request of the elasticsearch and http sinks"`
+	TargetWorkers    int    `cli:"target-workers" usage:"Number of concurrent requests of the elasticsearch and http sinks"`
+	SinkURL          string `cli:"sink-url" usage:"URL the http sink posts the documents to"`
+	SinkHeaders      string `cli:"sink-headers" usage:"Headers of the http sink requests separated by semicolons, e.g. 'X-Source: export; X-Team: ops'"`
+	SinkUser         string `cli:"sink-user" usage:"Basic auth user of the http sink"`
+	SinkPass         string `cli:"sink-pass" usage:"Basic auth password of the http sink"`
+	SinkToken        string `cli:"sink-token" usage:"Bearer token of the http sink"`
+	FailureFile      string `cli:"failure-file" usage:"Path to a file for documents the elasticsearch sink failed to index"`
+	Manifest         string `cli:"manifest" usage:"Path to a JSON manifest with query, cluster, document counts and SHA-256 hashes of the written files"`
+	ManifestKey      string `cli:"manifest-key" usage:"Path to a PEM encoded ed25519 private key to sign the manifest with"`
+	Progress         string `cli:"progress" usage:"How to report progress, a bar is only drawn on terminals if not set. [bar|json|none]"`
+	ProgressInterval int    `cli:"progress-interval" usage:"Seconds between the events of JSON progress"`
 	Trace            bool   `cli:"trace" usage:"Enable debug output"`
+	Version          string
 	Fields           []string
+	ExcludeFields    []string
+}
+
+// Defaults returns the settings of the CLI for all options that are not set.
+func Defaults() Flags {
+	return Flags{
+		ElasticURL:       "http://localhost:9200",
+		ElasticVerifySSL: false,
+		ElasticVersion:   7,
+		Index:            "logs-*",
+		Query:            "*",
+		OutFormat:        FormatCSV,
+		Outfile:          "output",
+		ScrollSize:       1000,
+		Timefield:        "@timestamp",
+		FetchMode:        "source",
+		JSONStyle:        "ndjson",
+		ArrowFormat:      ArrowFormatFile,
+		AvroCodec:        "deflate",
+		BulkOp:           "index",
+		Sink:             SinkFile,
+		TargetBatchSize:  1000,
+		TargetWorkers:    2,
+		MaxOpenFiles:     64,
+		S3PartSize:       "16mb",
+		RowGroupSize:     10000,
+		ProgressInterval: 5,
+	}
+}
+
+// repeatable are list flags that can be given several times. configstruct only keeps the last value of a flag,
+// so JoinRepeated merges all values into one comma separated list before the flags are parsed.
+var repeatable = map[string]bool{
+	"encrypt-to": true,
+}
+
+// JoinRepeated returns args with all occurrences of a repeatable flag replaced by a single flag at the position
+// of the first one that holds the comma separated values of all occurrences. Arguments after -- are kept as is.
+func JoinRepeated(args []string) []string {
+	joined := make([]string, 0, len(args))
+	values := make(map[string][]string)
+	positions := make(map[string]int)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			joined = append(joined, args[i:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			joined = append(joined, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !repeatable[name] || (!hasValue && i+1 == len(args)) {
+			joined = append(joined, arg)
+			continue
+		}
+		if !hasValue {
+			i++
+			value = args[i]
+		}
+
+		if _, ok := positions[name]; !ok {
+			positions[name] = len(joined)
+			joined = append(joined, "")
+		}
+		values[name] = append(values[name], value)
+	}
+
+	for name, position := range positions {
+		joined[position] = "--" + name + "=" + strings.Join(values[name], ",")
+	}
+
+	return joined
 }
@@ -0,0 +1,42 @@
+package flags
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJoinRepeated(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			"single",
+			[]string{"eqe", "--encrypt-to", "age1a", "-i", "logs"},
+			[]string{"eqe", "--encrypt-to=age1a", "-i", "logs"},
+		},
+		{
+			"repeated",
+			[]string{"eqe", "--encrypt-to", "age1a", "-i", "logs", "-encrypt-to=age1b,age1c", "--encrypt-to", "age1d"},
+			[]string{"eqe", "--encrypt-to=age1a,age1b,age1c,age1d", "-i", "logs"},
+		},
+		{
+			"after terminator",
+			[]string{"eqe", "--encrypt-to", "age1a", "--", "--encrypt-to", "age1b"},
+			[]string{"eqe", "--encrypt-to=age1a", "--", "--encrypt-to", "age1b"},
+		},
+		{
+			"missing value",
+			[]string{"eqe", "--encrypt-to"},
+			[]string{"eqe", "--encrypt-to"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := JoinRepeated(tt.args); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("JoinRepeated() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package formats
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+// Arrow writes all documents as Arrow IPC file (Feather v2) or stream with one column per leaf field
+// of the index mapping, fields marked as Array are list columns. Documents are written in record batches
+// of BatchSize rows.
+type Arrow struct {
+	Outfile    io.Writer
+	ProgessBar *pb.ProgressBar
+	Warnings   *Warnings
+	Mapping    []elastic.Field
+	BatchSize  int
+	Stream     bool
+}
+
+type arrowWriter interface {
+	Write(rec arrow.RecordBatch) error
+	Close() error
+}
+
+func (a Arrow) Run(ctx context.Context, hits <-chan elastic.SearchHit) error {
+	fields := elastic.LeafFields(a.Mapping)
+	if len(fields) == 0 {
+		return errors.New("no fields found in index mapping")
+	}
+
+	batchSize := a.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	schema := arrowSchema(fields)
+	mem := memory.NewGoAllocator()
+
+	var w arrowWriter
+	if a.Stream {
+		w = ipc.NewWriter(a.Outfile, ipc.WithSchema(schema), ipc.WithAllocator(mem))
+	} else {
+		fw, err := ipc.NewFileWriter(a.Outfile, ipc.WithSchema(schema), ipc.WithAllocator(mem))
+		if err != nil {
+			return err
+		}
+		w = fw
+	}
+
+	builder := array.NewRecordBuilder(mem, schema)
+	defer builder.Release()
+	arrays := newArrayWarnings(a.Warnings)
+
+	rows := 0
+	flush := func() error {
+		if rows == 0 {
+			return nil
+		}
+		rec := builder.NewRecordBatch()
+		defer rec.Release()
+		rows = 0
+		return w.Write(rec)
+	}
+
+	for hit := range hits {
+		document, err := decodeDocument(hit.GetSource())
+		if err != nil {
+			a.Warnings.Skip(1, "Error unmarshal JSON from ElasticSearch - %v", err)
+			continue
+		}
+
+		document = flatten(document)
+		for i, field := range fields {
+			kind := kindOf(field.Type)
+			val := document[field.Name]
+			switch {
+			case val == nil:
+				builder.Field(i).AppendNull()
+			case field.Array:
+				appendArrowValue(builder.Field(i), convertValues(kind, val))
+			default:
+				arrays.check(field.Name, kind, val)
+				appendArrowValue(builder.Field(i), convertValue(kind, val))
+			}
+		}
+		a.ProgessBar.Increment()
+
+		if rows++; rows >= batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+func arrowSchema(fields []elastic.Field) *arrow.Schema {
+	arrowFields := make([]arrow.Field, len(fields))
+
+	for i, field := range fields {
+		dataType := arrowType(field)
+		if field.Array {
+			dataType = arrow.ListOf(dataType)
+		}
+		arrowFields[i] = arrow.Field{Name: field.Name, Type: dataType, Nullable: true}
+	}
+
+	return arrow.NewSchema(arrowFields, nil)
+}
+
+func arrowType(field elastic.Field) arrow.DataType {
+	switch kindOf(field.Type) {
+	case kindInt:
+		return arrow.PrimitiveTypes.Int64
+	case kindFloat:
+		return arrow.PrimitiveTypes.Float64
+	case kindBool:
+		return arrow.FixedWidthTypes.Boolean
+	case kindTime:
+		if field.Type == "date_nanos" {
+			return arrow.FixedWidthTypes.Timestamp_ns
+		}
+		return arrow.FixedWidthTypes.Timestamp_ms
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+func appendArrowValue(builder array.Builder, val interface{}) {
+	if val == nil {
+		builder.AppendNull()
+		return
+	}
+
+	switch b := builder.(type) {
+	case *array.ListBuilder:
+		b.Append(true)
+		for _, value := range val.([]interface{}) {
+			appendArrowValue(b.ValueBuilder(), value)
+		}
+	case *array.Int64Builder:
+		b.Append(val.(int64))
+	case *array.Float64Builder:
+		b.Append(val.(float64))
+	case *array.BooleanBuilder:
+		b.Append(val.(bool))
+	case *array.TimestampBuilder:
+		unit := b.Type().(*arrow.TimestampType).Unit
+		ts, err := arrow.TimestampFromTime(val.(time.Time), unit)
+		if err != nil {
+			b.AppendNull()
+			return
+		}
+		b.Append(ts)
+	case *array.StringBuilder:
+		b.Append(val.(string))
+	default:
+		builder.AppendNull()
+	}
+}
@@ -0,0 +1,146 @@
+package formats
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+func TestArrow(t *testing.T) {
+	mapping := []elastic.Field{
+		{Name: "@timestamp", Type: "date"},
+		{Name: "bytes", Type: "long"},
+		{Name: "user", Type: elastic.TypeObject, Fields: []elastic.Field{{Name: "name", Type: "keyword"}}},
+	}
+
+	outfile, err := os.Create(filepath.Join(t.TempDir(), "out.arrow"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outfile.Close()
+
+	hits := make(chan elastic.SearchHit, 3)
+	hits <- testHit{source: []byte(`{"@timestamp":"2026-10-17T13:45:00Z","bytes":512,"user":{"name":"jane"}}`)}
+	hits <- testHit{source: []byte(`{"bytes":"1024"}`)}
+	hits <- testHit{source: []byte(`{"user":{"name":"joe"}}`)}
+	close(hits)
+
+	a := Arrow{
+		Outfile:    outfile,
+		ProgessBar: pb.New(3),
+		Mapping:    mapping,
+		BatchSize:  2,
+	}
+	if err := a.Run(context.Background(), hits); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if _, err := outfile.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := ipc.NewFileReader(outfile)
+	if err != nil {
+		t.Fatalf("NewFileReader() error = %v", err)
+	}
+	defer reader.Close()
+
+	if reader.NumRecords() != 2 {
+		t.Errorf("expected 2 record batches, got %d", reader.NumRecords())
+	}
+
+	schema := reader.Schema()
+	wantTypes := map[string]arrow.Type{"@timestamp": arrow.TIMESTAMP, "bytes": arrow.INT64, "user.name": arrow.STRING}
+	for name, want := range wantTypes {
+		indices := schema.FieldIndices(name)
+		if len(indices) != 1 || schema.Field(indices[0]).Type.ID() != want {
+			t.Errorf("field %s: expected type %s", name, want)
+		}
+	}
+
+	rec, err := reader.RecordBatch(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bytes := rec.Column(1).(*array.Int64)
+	if bytes.Value(0) != 512 || bytes.Value(1) != 1024 {
+		t.Errorf("unexpected bytes values %v", bytes)
+	}
+	if !rec.Column(2).IsNull(1) {
+		t.Errorf("expected missing user.name to be null")
+	}
+}
+
+func TestArrowArrays(t *testing.T) {
+	mapping := []elastic.Field{
+		{Name: "bytes", Type: "long"},
+		{Name: "hosts", Type: elastic.TypeObject, Fields: []elastic.Field{{Name: "name", Type: "keyword"}}, Array: true},
+		{Name: "ports", Type: "long", Array: true},
+	}
+
+	outfile, err := os.Create(filepath.Join(t.TempDir(), "out.arrow"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outfile.Close()
+
+	hits := make(chan elastic.SearchHit, 3)
+	hits <- testHit{source: []byte(`{"bytes":[1,2],"ports":[80,443],"hosts":[{"name":"a"},{"name":"b"}]}`)}
+	hits <- testHit{source: []byte(`{"ports":22}`)}
+	hits <- testHit{source: []byte(`{"bytes":[3,4]}`)}
+	close(hits)
+
+	var logs strings.Builder
+	a := Arrow{
+		Outfile:    outfile,
+		ProgessBar: pb.New(3),
+		Warnings:   &Warnings{Logger: log.New(&logs, "", 0)},
+		Mapping:    mapping,
+	}
+	if err := a.Run(context.Background(), hits); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// arrays in columns that are not lists are reported once
+	if got := strings.Count(logs.String(), "Field bytes holds arrays"); got != 1 {
+		t.Errorf("expected one warning about bytes, got %q", logs.String())
+	}
+
+	if _, err := outfile.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := ipc.NewFileReader(outfile)
+	if err != nil {
+		t.Fatalf("NewFileReader() error = %v", err)
+	}
+	defer reader.Close()
+
+	rec, err := reader.RecordBatch(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts := rec.Column(1).(*array.List)
+	if got := hosts.ValueStr(0); got != `["{\"name\":\"a\"}","{\"name\":\"b\"}"]` {
+		t.Errorf("unexpected hosts %s", got)
+	}
+	ports := rec.Column(2).(*array.List)
+	if got := ports.ValueStr(0); got != "[80,443]" {
+		t.Errorf("unexpected ports %s", got)
+	}
+	if got := ports.ValueStr(1); got != "[22]" {
+		t.Errorf("unexpected single port %s", got)
+	}
+	if !ports.IsNull(2) {
+		t.Errorf("expected missing ports to be null")
+	}
+}
@@ -0,0 +1,270 @@
+package formats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hamba/avro/v2/ocf"
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+// Avro writes all documents into an Avro Object Container File with a schema generated from the index
+// mapping. Every field is a union with null, objects become records and nested fields arrays of records.
+// Fields marked as Array in the mapping are arrays of their values or records.
+type Avro struct {
+	Outfile    io.Writer
+	ProgessBar *pb.ProgressBar
+	Warnings   *Warnings
+	Mapping    []elastic.Field
+	Codec      string
+}
+
+// avroField holds a mapping field together with its Avro schema and the name of its union branch,
+// that is needed to encode a value into the nullable union.
+type avroField struct {
+	field    elastic.Field
+	name     string
+	union    string
+	schema   interface{}
+	children []avroField
+}
+
+func (a Avro) Run(ctx context.Context, hits <-chan elastic.SearchHit) error {
+	fields, schema, err := avroSchema(a.Mapping)
+	if err != nil {
+		return err
+	}
+
+	codec, err := avroCodec(a.Codec)
+	if err != nil {
+		return err
+	}
+
+	enc, err := ocf.NewEncoder(schema, a.Outfile, ocf.WithCodec(codec))
+	if err != nil {
+		return err
+	}
+
+	arrays := newArrayWarnings(a.Warnings)
+
+	for hit := range hits {
+		document, err := decodeDocument(hit.GetSource())
+		if err != nil {
+			a.Warnings.Skip(1, "Error unmarshal JSON from ElasticSearch - %v", err)
+			continue
+		}
+
+		if err := enc.Encode(avroRecord(fields, unflatten(document), arrays, "")); err != nil {
+			return err
+		}
+		a.ProgessBar.Increment()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+
+	return enc.Close()
+}
+
+// AvroSchema returns the Avro schema that is generated from the given mapping as JSON.
+func AvroSchema(mapping []elastic.Field) (string, error) {
+	_, schema, err := avroSchema(mapping)
+	return schema, err
+}
+
+func avroCodec(name string) (ocf.CodecName, error) {
+	switch name {
+	case "", "deflate":
+		return ocf.Deflate, nil
+	case "snappy":
+		return ocf.Snappy, nil
+	case "null", "none":
+		return ocf.Null, nil
+	default:
+		return "", fmt.Errorf("unknown avro codec %s", name)
+	}
+}
+
+func avroSchema(mapping []elastic.Field) ([]avroField, string, error) {
+	if len(mapping) == 0 {
+		return nil, "", errors.New("no fields found in index mapping")
+	}
+
+	fields := avroFields(mapping, "document")
+	schema, err := json.Marshal(avroRecordSchema("document", fields))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return fields, string(schema), nil
+}
+
+func avroFields(mapping []elastic.Field, recordName string) []avroField {
+	fields := make([]avroField, 0, len(mapping))
+	names := map[string]bool{}
+
+	for _, field := range mapping {
+		name := avroName(field.Name)
+		for names[name] {
+			name += "_"
+		}
+		names[name] = true
+
+		f := avroField{field: field, name: name}
+
+		switch {
+		case field.IsGroup() && len(field.Fields) > 0:
+			childName := recordName + "_" + name
+			f.children = avroFields(field.Fields, childName)
+			record := avroRecordSchema(childName, f.children)
+			if field.Type == elastic.TypeNested || field.Array {
+				f.union = "array"
+				f.schema = map[string]interface{}{"type": "array", "items": record}
+			} else {
+				f.union = childName
+				f.schema = record
+			}
+		case field.Array:
+			_, items := avroType(field)
+			f.union = "array"
+			f.schema = map[string]interface{}{"type": "array", "items": items}
+		default:
+			f.union, f.schema = avroType(field)
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields
+}
+
+// avroType returns the union branch name and schema of a field with a plain value.
+func avroType(field elastic.Field) (string, interface{}) {
+	switch kindOf(field.Type) {
+	case kindInt:
+		return "long", "long"
+	case kindFloat:
+		return "double", "double"
+	case kindBool:
+		return "boolean", "boolean"
+	case kindTime:
+		logicalType := "timestamp-millis"
+		if field.Type == "date_nanos" {
+			logicalType = "timestamp-micros"
+		}
+		return "long." + logicalType, map[string]interface{}{"type": "long", "logicalType": logicalType}
+	default:
+		return "string", "string"
+	}
+}
+
+func avroRecordSchema(name string, fields []avroField) map[string]interface{} {
+	schemaFields := make([]interface{}, len(fields))
+
+	for i, f := range fields {
+		schemaField := map[string]interface{}{
+			"name":    f.name,
+			"type":    []interface{}{"null", f.schema},
+			"default": nil,
+		}
+		if f.name != f.field.Name {
+			schemaField["doc"] = f.field.Name
+		}
+		schemaFields[i] = schemaField
+	}
+
+	return map[string]interface{}{
+		"type":   "record",
+		"name":   name,
+		"fields": schemaFields,
+	}
+}
+
+// avroName replaces all characters that are not allowed in Avro names, e.g. "@timestamp" becomes "_timestamp".
+func avroName(name string) string {
+	var sb strings.Builder
+	for i, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_':
+			sb.WriteRune(c)
+		case c >= '0' && c <= '9':
+			if i == 0 {
+				sb.WriteRune('_')
+			}
+			sb.WriteRune(c)
+		default:
+			sb.WriteRune('_')
+		}
+	}
+	return sb.String()
+}
+
+// avroRecord converts a document into a record with all values wrapped in their union branch. Fields
+// are named with their prefix in warnings about arrays.
+func avroRecord(fields []avroField, document map[string]interface{}, arrays *arrayWarnings, prefix string) map[string]interface{} {
+	record := make(map[string]interface{}, len(fields))
+
+	for _, f := range fields {
+		record[f.name] = avroValue(f, document[f.field.Name], arrays, prefix)
+	}
+
+	return record
+}
+
+func avroValue(f avroField, val interface{}, arrays *arrayWarnings, prefix string) interface{} {
+	if val == nil {
+		return nil
+	}
+
+	if f.children == nil {
+		kind := kindOf(f.field.Type)
+		if f.field.Array {
+			return map[string]interface{}{f.union: convertValues(kind, val)}
+		}
+		arrays.check(prefix+f.field.Name, kind, val)
+		val = convertValue(kind, val)
+		if val == nil {
+			return nil
+		}
+		return map[string]interface{}{f.union: val}
+	}
+
+	var objects []map[string]interface{}
+	switch val := val.(type) {
+	case map[string]interface{}:
+		objects = append(objects, val)
+	case []interface{}:
+		for _, object := range val {
+			if object, ok := object.(map[string]interface{}); ok {
+				objects = append(objects, object)
+			}
+		}
+	}
+
+	childPrefix := prefix + f.field.Name + "."
+	if f.field.Type == elastic.TypeNested || f.field.Array {
+		records := make([]interface{}, len(objects))
+		for i, object := range objects {
+			records[i] = avroRecord(f.children, object, arrays, childPrefix)
+		}
+		return map[string]interface{}{f.union: records}
+	}
+
+	if len(objects) == 0 {
+		return nil
+	}
+	if len(objects) > 1 {
+		arrays.truncated(prefix + f.field.Name)
+	}
+
+	return map[string]interface{}{f.union: avroRecord(f.children, objects[0], arrays, childPrefix)}
+}
@@ -0,0 +1,161 @@
+package formats
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hamba/avro/v2/ocf"
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+func TestAvro(t *testing.T) {
+	mapping := []elastic.Field{
+		{Name: "@timestamp", Type: "date"},
+		{Name: "bytes", Type: "long"},
+		{Name: "tags", Type: elastic.TypeNested, Fields: []elastic.Field{{Name: "key", Type: "keyword"}}},
+		{Name: "user", Type: elastic.TypeObject, Fields: []elastic.Field{{Name: "name", Type: "keyword"}}},
+	}
+
+	schema, err := AvroSchema(mapping)
+	if err != nil {
+		t.Fatalf("AvroSchema() error = %v", err)
+	}
+	for _, want := range []string{`"name":"_timestamp"`, `"doc":"@timestamp"`, `"name":"document_user"`, `"logicalType":"timestamp-millis"`} {
+		if !strings.Contains(schema, want) {
+			t.Errorf("AvroSchema() = %s, missing %s", schema, want)
+		}
+	}
+
+	outfile, err := os.Create(filepath.Join(t.TempDir(), "out.avro"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outfile.Close()
+
+	hits := make(chan elastic.SearchHit, 2)
+	hits <- testHit{source: []byte(`{"@timestamp":"2026-10-17T13:45:00Z","bytes":512,"user":{"name":"jane"},"tags":[{"key":"a"},{"key":"b"}]}`)}
+	hits <- testHit{source: []byte(`{"bytes":null}`)}
+	close(hits)
+
+	a := Avro{
+		Outfile:    outfile,
+		ProgessBar: pb.New(2),
+		Mapping:    mapping,
+		Codec:      "snappy",
+	}
+	if err := a.Run(context.Background(), hits); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if _, err := outfile.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	dec, err := ocf.NewDecoder(outfile)
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+
+	var records []map[string]interface{}
+	for dec.HasNext() {
+		var record map[string]interface{}
+		if err := dec.Decode(&record); err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		records = append(records, record)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if records[0]["bytes"] != int64(512) {
+		t.Errorf("unexpected bytes value %v", records[0]["bytes"])
+	}
+	if records[1]["user"] != nil || records[1]["bytes"] != nil {
+		t.Errorf("expected null values, got %v", records[1])
+	}
+}
+
+func TestAvroArrays(t *testing.T) {
+	mapping := []elastic.Field{
+		{Name: "@timestamp", Type: "date", Array: true},
+		{Name: "bytes", Type: "long"},
+		{Name: "hosts", Type: elastic.TypeObject, Fields: []elastic.Field{{Name: "name", Type: "keyword"}}, Array: true},
+		{Name: "ports", Type: "long", Array: true},
+	}
+
+	outfile, err := os.Create(filepath.Join(t.TempDir(), "out.avro"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outfile.Close()
+
+	hits := make(chan elastic.SearchHit, 2)
+	hits <- testHit{source: []byte(`{"@timestamp":"2026-10-17T13:45:00Z","bytes":[1,2],"ports":[80,443],"hosts":[{"name":"a"},{"name":"b"}]}`)}
+	hits <- testHit{source: []byte(`{"bytes":[3,4],"ports":22}`)}
+	close(hits)
+
+	var logs strings.Builder
+	a := Avro{
+		Outfile:    outfile,
+		ProgessBar: pb.New(2),
+		Warnings:   &Warnings{Logger: log.New(&logs, "", 0)},
+		Mapping:    mapping,
+	}
+	if err := a.Run(context.Background(), hits); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// arrays in fields that are not arrays are reported once
+	if got := strings.Count(logs.String(), "Field bytes holds arrays"); got != 1 {
+		t.Errorf("expected one warning about bytes, got %q", logs.String())
+	}
+
+	if _, err := outfile.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	dec, err := ocf.NewDecoder(outfile)
+	if err != nil {
+		t.Fatalf("NewDecoder() error = %v", err)
+	}
+
+	var records []map[string]interface{}
+	for dec.HasNext() {
+		var record map[string]interface{}
+		if err := dec.Decode(&record); err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		records = append(records, record)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	if ports, _ := avroArray(records[0]["ports"]); len(ports) != 2 || ports[1] != int64(443) {
+		t.Errorf("unexpected ports %v", records[0]["ports"])
+	}
+	if ports, _ := avroArray(records[1]["ports"]); len(ports) != 1 || ports[0] != int64(22) {
+		t.Errorf("unexpected single port %v", records[1]["ports"])
+	}
+	if hosts, _ := avroArray(records[0]["hosts"]); len(hosts) != 2 {
+		t.Errorf("unexpected hosts %v", records[0]["hosts"])
+	}
+	if timestamps, _ := avroArray(records[0]["_timestamp"]); len(timestamps) != 1 {
+		t.Errorf("unexpected timestamps %v", records[0]["_timestamp"])
+	}
+	if records[0]["bytes"] != int64(1) {
+		t.Errorf("expected first bytes value, got %v", records[0]["bytes"])
+	}
+}
+
+// avroArray returns the array of a decoded nullable union.
+func avroArray(val interface{}) ([]interface{}, bool) {
+	union, _ := val.(map[string]interface{})
+	values, ok := union["array"].([]interface{})
+	return values, ok
+}
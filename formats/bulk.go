@@ -0,0 +1,155 @@
+package formats
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+// Bulk op types that can be used in the action line.
+const (
+	BulkOpIndex  = "index"
+	BulkOpCreate = "create"
+)
+
+// Bulk writes all documents in the NDJSON format of the ElasticSearch bulk API, an action line with the
+// index, id and routing of the hit followed by its source. If MaxBytes is set, a new file is started
+// before a file would exceed that size. Following files are named after Path with a number appended,
+// e.g. out.ndjson, out-1.ndjson, out-2.ndjson, and opened with Create, which defaults to os.Create.
+type Bulk struct {
+	Outfile    io.Writer
+	ProgessBar *pb.ProgressBar
+	Warnings   *Warnings
+	Path       string
+	Create     func(path string) (io.WriteCloser, error)
+	Index      string
+	OpType     string
+	DropID     bool
+	MaxBytes   int64
+}
+
+type bulkMeta struct {
+	Index   string `json:"_index,omitempty"`
+	ID      string `json:"_id,omitempty"`
+	Routing string `json:"routing,omitempty"`
+}
+
+func (b Bulk) Run(ctx context.Context, hits <-chan elastic.SearchHit) error {
+	opType := b.OpType
+	if opType == "" {
+		opType = BulkOpIndex
+	}
+	if opType != BulkOpIndex && opType != BulkOpCreate {
+		return fmt.Errorf("unknown bulk op type %s", opType)
+	}
+
+	create := b.Create
+	if create == nil {
+		create = func(path string) (io.WriteCloser, error) {
+			return os.Create(path)
+		}
+	}
+
+	outfile := b.Outfile
+	var part io.WriteCloser
+	partNumber := 0
+	var written int64
+
+	defer func() {
+		if part != nil {
+			part.Close()
+		}
+	}()
+
+	for hit := range hits {
+		entry, err := b.entry(opType, hit)
+		if err != nil {
+			b.Warnings.Skip(1, "Error unmarshal JSON from ElasticSearch - %v", err)
+			continue
+		}
+
+		if b.MaxBytes > 0 && written > 0 && written+int64(len(entry)) > b.MaxBytes {
+			if part != nil {
+				err := part.Close()
+				part = nil
+				if err != nil {
+					return err
+				}
+			}
+			partNumber++
+			part, err = create(bulkPartName(b.Path, partNumber))
+			if err != nil {
+				return err
+			}
+			outfile = part
+			written = 0
+		}
+
+		n, err := outfile.Write(entry)
+		if err != nil {
+			return err
+		}
+		written += int64(n)
+		b.ProgessBar.Increment()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+
+	if part != nil {
+		last := part
+		part = nil
+		return last.Close()
+	}
+
+	return nil
+}
+
+// entry returns the action and source line of a hit.
+func (b Bulk) entry(opType string, hit elastic.SearchHit) ([]byte, error) {
+	meta := bulkMeta{Index: hit.GetIndex(), ID: hit.GetID(), Routing: hit.GetRouting()}
+	if b.Index != "" {
+		meta.Index = b.Index
+	}
+	if b.DropID {
+		meta.ID = ""
+	}
+
+	action, err := json.Marshal(map[string]bulkMeta{opType: meta})
+	if err != nil {
+		return nil, err
+	}
+
+	var entry bytes.Buffer
+	entry.Grow(len(action) + len(hit.GetSource()) + 2)
+	entry.Write(action)
+	entry.WriteByte('\n')
+	// the source has to be on a single line
+	if err := json.Compact(&entry, hit.GetSource()); err != nil {
+		return nil, err
+	}
+	entry.WriteByte('\n')
+
+	return entry.Bytes(), nil
+}
+
+// bulkPartName returns the name of the given part of a split bulk file, the first part keeps the original name.
+func bulkPartName(path string, part int) string {
+	if part == 0 {
+		return path
+	}
+	// keep the extension of the data in front of the compression and encryption extensions
+	ext := FileExt(path)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), part, ext)
+}
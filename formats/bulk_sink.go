@@ -0,0 +1,277 @@
+package formats
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+// BulkSink sends all documents directly to the _bulk API of another cluster. The action lines are built
+// like the ones of the Bulk format. Batches of BatchSize documents are sent by Workers concurrent requests,
+// items that are rejected with 429 are retried with backoff. All other failed items are written as JSON
+// lines to FailureFile, or logged if no failure file is given.
+type BulkSink struct {
+	Client      *http.Client
+	URL         string
+	User        string
+	Pass        string
+	ProgessBar  *pb.ProgressBar
+	Warnings    *Warnings
+	Index       string
+	OpType      string
+	DropID      bool
+	BatchSize   int
+	Workers     int
+	MaxRetries  int
+	FailureFile *os.File
+	// Retries counts the retried requests, if set
+	Retries *atomic.Int64
+}
+
+// bulkFailure is written to the failure file for every document that could not be indexed.
+type bulkFailure struct {
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error,omitempty"`
+	Action json.RawMessage `json:"action"`
+	Source json.RawMessage `json:"source"`
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+func (s BulkSink) Run(ctx context.Context, hits <-chan elastic.SearchHit) error {
+	opType := s.OpType
+	if opType == "" {
+		opType = BulkOpIndex
+	}
+	if opType != BulkOpIndex && opType != BulkOpCreate {
+		return fmt.Errorf("unknown bulk op type %s", opType)
+	}
+
+	batchSize := s.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	workers := s.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	formatter := Bulk{Index: s.Index, DropID: s.DropID}
+	batches := make(chan [][]byte, workers)
+	failureMu := sync.Mutex{}
+	failures := 0
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	for i := 0; i < workers; i++ {
+		g.Go(func() error {
+			for batch := range batches {
+				failed, err := s.send(ctx, batch)
+				if err != nil {
+					return err
+				}
+
+				if len(failed) > 0 {
+					failureMu.Lock()
+					failures += len(failed)
+					err = s.writeFailures(failed)
+					failureMu.Unlock()
+					if err != nil {
+						return err
+					}
+				}
+
+				s.ProgessBar.Add(len(batch) - len(failed))
+			}
+			return nil
+		})
+	}
+
+	g.Go(func() error {
+		defer close(batches)
+
+		batch := make([][]byte, 0, batchSize)
+		for hit := range hits {
+			entry, err := formatter.entry(opType, hit)
+			if err != nil {
+				s.Warnings.Skip(1, "Error unmarshal JSON from ElasticSearch - %v", err)
+				continue
+			}
+
+			batch = append(batch, entry)
+			if len(batch) < batchSize {
+				continue
+			}
+
+			select {
+			case batches <- batch:
+				batch = make([][]byte, 0, batchSize)
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if len(batch) > 0 {
+			select {
+			case batches <- batch:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		return nil
+	})
+
+	err := g.Wait()
+	if failures > 0 {
+		s.Warnings.Skip(failures, "%d documents could not be indexed", failures)
+	}
+
+	return err
+}
+
+// send posts a batch of bulk entries and retries all items that were rejected because the target cluster
+// is overloaded. It returns the items that failed for other reasons or could not be sent after all retries.
+func (s BulkSink) send(ctx context.Context, entries [][]byte) ([]bulkFailure, error) {
+	maxRetries := s.MaxRetries
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+
+	var failures []bulkFailure
+	backoff := 500 * time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+		response, retryable, err := s.post(ctx, entries)
+		if err != nil && (!retryable || attempt >= maxRetries) {
+			return nil, err
+		}
+
+		var rejected [][]byte
+		if err == nil {
+			for i, item := range response.Items {
+				if i >= len(entries) {
+					break
+				}
+				for _, result := range item {
+					switch {
+					case result.Status == http.StatusTooManyRequests:
+						rejected = append(rejected, entries[i])
+					case result.Status >= 300:
+						failures = append(failures, newBulkFailure(entries[i], result.Status, result.Error))
+					}
+				}
+			}
+		} else {
+			rejected = entries
+		}
+
+		if len(rejected) == 0 {
+			return failures, nil
+		}
+
+		if attempt >= maxRetries {
+			for _, entry := range rejected {
+				failures = append(failures, newBulkFailure(entry, http.StatusTooManyRequests, nil))
+			}
+			return failures, nil
+		}
+
+		if s.Retries != nil {
+			s.Retries.Add(1)
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+		entries = rejected
+	}
+}
+
+// post sends the entries to the _bulk API. Errors of overloaded or unavailable clusters are reported as retryable.
+func (s BulkSink) post(ctx context.Context, entries [][]byte) (*bulkResponse, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(s.URL, "/")+"/_bulk", bytes.NewReader(bytes.Join(entries, nil)))
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if s.User != "" && s.Pass != "" {
+		req.SetBasicAuth(s.User, s.Pass)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("bulk request failed with status %d: %s", resp.StatusCode, body)
+		switch resp.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return nil, true, err
+		default:
+			return nil, false, err
+		}
+	}
+
+	var response bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, false, err
+	}
+
+	return &response, false, nil
+}
+
+func (s BulkSink) writeFailures(failures []bulkFailure) error {
+	for _, failure := range failures {
+		data, err := json.Marshal(failure)
+		if err != nil {
+			return err
+		}
+
+		if s.FailureFile == nil {
+			s.Warnings.Logf("Failed to index document - %s", data)
+			continue
+		}
+
+		if _, err := fmt.Fprintln(s.FailureFile, string(data)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newBulkFailure(entry []byte, status int, reason json.RawMessage) bulkFailure {
+	action, source, _ := bytes.Cut(bytes.TrimSuffix(entry, []byte("\n")), []byte("\n"))
+	return bulkFailure{Status: status, Error: reason, Action: action, Source: source}
+}
@@ -0,0 +1,109 @@
+package formats
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+func TestBulkSink(t *testing.T) {
+	mu := sync.Mutex{}
+	indexed := map[string]int{}
+	rejected := false
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if user, pass, _ := r.BasicAuth(); user != "elastic" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		var items []interface{}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var action map[string]map[string]string
+			if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+				t.Errorf("invalid action line %s", scanner.Text())
+			}
+			scanner.Scan()
+
+			id := action["create"]["_id"]
+			status := http.StatusCreated
+			switch {
+			case id == "2" && !rejected:
+				// reject the first attempt to make the sink retry
+				rejected = true
+				status = http.StatusTooManyRequests
+			case id == "3":
+				status = http.StatusBadRequest
+			default:
+				indexed[id]++
+			}
+			items = append(items, map[string]interface{}{"create": map[string]interface{}{"_id": id, "status": status}})
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"errors": true, "items": items})
+	}))
+	defer server.Close()
+
+	failureFile, err := os.Create(filepath.Join(t.TempDir(), "failures.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer failureFile.Close()
+
+	hits := make(chan elastic.SearchHit, 4)
+	for i := 1; i <= 4; i++ {
+		hits <- testHit{source: []byte(fmt.Sprintf(`{"n":%d}`, i)), id: fmt.Sprint(i), index: "logs"}
+	}
+	close(hits)
+
+	s := BulkSink{
+		URL:         server.URL,
+		User:        "elastic",
+		Pass:        "secret",
+		ProgessBar:  pb.New(4),
+		Index:       "copy",
+		OpType:      BulkOpCreate,
+		BatchSize:   2,
+		Workers:     2,
+		MaxRetries:  2,
+		FailureFile: failureFile,
+	}
+	if err := s.Run(context.Background(), hits); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	for _, id := range []string{"1", "2", "4"} {
+		if indexed[id] != 1 {
+			t.Errorf("document %s indexed %d times", id, indexed[id])
+		}
+	}
+
+	failures, err := os.ReadFile(failureFile.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"status":400,"action":{"create":{"_index":"copy","_id":"3"}},"source":{"n":3}}`
+	if strings.TrimSpace(string(failures)) != want {
+		t.Errorf("failures = %s, want %s", failures, want)
+	}
+}
@@ -0,0 +1,115 @@
+package formats
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+func TestBulk(t *testing.T) {
+	tests := []struct {
+		name  string
+		bulk  Bulk
+		files map[string]string
+	}{
+		{
+			"default",
+			Bulk{},
+			map[string]string{
+				"out.ndjson": `{"index":{"_index":"logs-1","_id":"1","routing":"a"}}
+{"message":"one"}
+{"index":{"_index":"logs-1","_id":"2"}}
+{"message":"two"}
+`,
+			},
+		},
+		{
+			"rewrite index, drop id and create",
+			Bulk{Index: "copy", DropID: true, OpType: BulkOpCreate},
+			map[string]string{
+				"out.ndjson": `{"create":{"_index":"copy","routing":"a"}}
+{"message":"one"}
+{"create":{"_index":"copy"}}
+{"message":"two"}
+`,
+			},
+		},
+		{
+			"split by size",
+			Bulk{MaxBytes: 80},
+			map[string]string{
+				"out.ndjson": `{"index":{"_index":"logs-1","_id":"1","routing":"a"}}
+{"message":"one"}
+`,
+				"out-1.ndjson": `{"index":{"_index":"logs-1","_id":"2"}}
+{"message":"two"}
+`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out.ndjson")
+			outfile, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer outfile.Close()
+
+			hits := make(chan elastic.SearchHit, 2)
+			hits <- testHit{source: []byte("{\n  \"message\": \"one\"\n}"), id: "1", index: "logs-1", routing: "a"}
+			hits <- testHit{source: []byte(`{"message":"two"}`), id: "2", index: "logs-1"}
+			close(hits)
+
+			b := tt.bulk
+			b.Outfile = outfile
+			b.ProgessBar = pb.New(2)
+			b.Path = path
+			if err := b.Run(context.Background(), hits); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			entries, _ := os.ReadDir(filepath.Dir(path))
+			if len(entries) != len(tt.files) {
+				t.Errorf("expected %d files, got %d", len(tt.files), len(entries))
+			}
+			for name, want := range tt.files {
+				got, err := os.ReadFile(filepath.Join(filepath.Dir(path), name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != want {
+					t.Errorf("%s = %s, want %s", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_bulkPartName(t *testing.T) {
+	tests := []struct {
+		path string
+		part int
+		want string
+	}{
+		{"out.ndjson", 0, "out.ndjson"},
+		{"out.ndjson", 2, "out-2.ndjson"},
+		{"out.ndjson.gz", 1, "out-1.ndjson.gz"},
+		{"out.ndjson.gz.age", 1, "out-1.ndjson.gz.age"},
+		{"dir/out", 1, "dir/out-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := bulkPartName(tt.path, tt.part); got != tt.want {
+				t.Errorf("bulkPartName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
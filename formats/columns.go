@@ -0,0 +1,419 @@
+package formats
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+// Column is a single output column defined by a --fields spec. A spec entry can be a plain
+// field name or wildcard pattern, a rename like "ts=@timestamp", a metadata field like "_id" or "_index",
+// a quoted constant or one of the functions concat(), date() and len().
+type Column struct {
+	Name string
+	expr expression
+}
+
+type expression interface {
+	eval(hit elastic.SearchHit, document map[string]interface{}) interface{}
+	fields() []string
+}
+
+// Value returns the value of the column for the given hit and its flattened source document.
+func (c Column) Value(hit elastic.SearchHit, document map[string]interface{}) interface{} {
+	return c.expr.eval(hit, document)
+}
+
+// ParseColumns parses a comma separated column spec.
+func ParseColumns(spec string) ([]Column, error) {
+	var columns []Column
+
+	for _, item := range splitTopLevel(spec, ',') {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		column, err := parseColumn(item)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+
+	return columns, nil
+}
+
+// LoadColumns reads a column spec from a file. Every line can hold one or more comma separated
+// column definitions, empty lines and lines starting with # are ignored.
+func LoadColumns(path string) ([]Column, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var columns []Column
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		lineColumns, err := ParseColumns(line)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, lineColumns...)
+	}
+
+	return columns, scanner.Err()
+}
+
+// ExpandColumns replaces wildcard columns like "user.*" with one column for every matching field name,
+// sorted by name. The names are usually the leaf fields of the index mapping. A wildcard column with an
+// alias like "u=user.*" keeps the alias as prefix of the expanded names, e.g. "u.name". Other columns are
+// returned unchanged.
+func ExpandColumns(columns []Column, names []string) []Column {
+	var expanded []Column
+
+	for _, column := range columns {
+		pattern, ok := column.wildcard()
+		if !ok {
+			expanded = append(expanded, column)
+			continue
+		}
+
+		var matches []string
+		for _, name := range names {
+			if ok, _ := path.Match(pattern, name); ok {
+				matches = append(matches, name)
+			}
+		}
+		sort.Strings(matches)
+
+		// the fixed part of the pattern up to the last dot is replaced by the alias
+		prefix := pattern[:strings.IndexAny(pattern, "*?[")]
+		prefix = prefix[:strings.LastIndexByte(prefix, '.')+1]
+
+		for _, name := range matches {
+			columnName := name
+			if column.Name != pattern {
+				columnName = column.Name + "." + strings.TrimPrefix(name, prefix)
+			}
+			expanded = append(expanded, Column{Name: columnName, expr: fieldExpr(name)})
+		}
+	}
+
+	return expanded
+}
+
+// HasWildcards reports whether any of the columns is a wildcard pattern that needs ExpandColumns.
+func HasWildcards(columns []Column) bool {
+	for _, column := range columns {
+		if _, ok := column.wildcard(); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcard returns the pattern of a wildcard column.
+func (c Column) wildcard() (string, bool) {
+	pattern, ok := c.expr.(fieldExpr)
+	if !ok || !strings.ContainsAny(string(pattern), "*?[") {
+		return "", false
+	}
+	return string(pattern), true
+}
+
+// documentFields returns the leaf field names of a flattened document.
+func documentFields(document map[string]interface{}) []string {
+	var names []string
+	for key, val := range document {
+		if _, isMap := val.(map[string]interface{}); !isMap {
+			names = append(names, key)
+		}
+	}
+	return names
+}
+
+// ColumnNames returns the header names of the given columns.
+func ColumnNames(columns []Column) []string {
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// SourceFields returns all document fields referenced by the given columns, in order and without duplicates.
+func SourceFields(columns []Column) []string {
+	var fields []string
+	seen := map[string]bool{}
+
+	for _, column := range columns {
+		for _, field := range column.expr.fields() {
+			if !seen[field] {
+				seen[field] = true
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	return fields
+}
+
+func parseColumn(item string) (Column, error) {
+	name := ""
+	if i := strings.IndexByte(item, '='); i > 0 && !strings.ContainsAny(item[:i], "'\"(") {
+		name = strings.TrimSpace(item[:i])
+		item = strings.TrimSpace(item[i+1:])
+	}
+
+	expr, err := parseExpression(item)
+	if err != nil {
+		return Column{}, err
+	}
+
+	if name == "" {
+		name = item
+	}
+
+	return Column{Name: name, expr: expr}, nil
+}
+
+func parseExpression(s string) (expression, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, errors.New("empty column expression")
+	}
+
+	if s[0] == '\'' || s[0] == '"' {
+		if len(s) < 2 || s[len(s)-1] != s[0] {
+			return nil, fmt.Errorf("unterminated string in column %q", s)
+		}
+		return constExpr(s[1 : len(s)-1]), nil
+	}
+
+	if i := strings.IndexByte(s, '('); i > 0 {
+		if s[len(s)-1] != ')' {
+			return nil, fmt.Errorf("missing closing parenthesis in column %q", s)
+		}
+
+		fn := funcExpr{name: strings.ToLower(strings.TrimSpace(s[:i]))}
+		for _, arg := range splitTopLevel(s[i+1:len(s)-1], ',') {
+			argExpr, err := parseExpression(arg)
+			if err != nil {
+				return nil, err
+			}
+			fn.args = append(fn.args, argExpr)
+		}
+
+		return fn, fn.validate()
+	}
+
+	switch s {
+	case "_id", "_index":
+		return metaExpr(s), nil
+	}
+
+	return fieldExpr(s), nil
+}
+
+// splitTopLevel splits s at sep but ignores separators inside quotes and parentheses.
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	var quote byte
+	depth := 0
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+type fieldExpr string
+
+func (e fieldExpr) eval(_ elastic.SearchHit, document map[string]interface{}) interface{} {
+	return document[string(e)]
+}
+
+func (e fieldExpr) fields() []string {
+	return []string{string(e)}
+}
+
+type metaExpr string
+
+func (e metaExpr) eval(hit elastic.SearchHit, _ map[string]interface{}) interface{} {
+	switch e {
+	case "_id":
+		return hit.GetID()
+	case "_index":
+		return hit.GetIndex()
+	}
+	return nil
+}
+
+func (e metaExpr) fields() []string {
+	return nil
+}
+
+type constExpr string
+
+func (e constExpr) eval(_ elastic.SearchHit, _ map[string]interface{}) interface{} {
+	return string(e)
+}
+
+func (e constExpr) fields() []string {
+	return nil
+}
+
+type funcExpr struct {
+	name string
+	args []expression
+}
+
+func (e funcExpr) validate() error {
+	switch e.name {
+	case "concat":
+		if len(e.args) == 0 {
+			return errors.New("concat() needs at least one argument")
+		}
+	case "len":
+		if len(e.args) != 1 {
+			return errors.New("len() needs exactly one argument")
+		}
+	case "date":
+		if len(e.args) != 2 {
+			return errors.New("date() needs a field and a date part or layout")
+		}
+		if _, ok := e.args[1].(constExpr); !ok {
+			return errors.New("date() needs a quoted date part or layout as second argument")
+		}
+	default:
+		return fmt.Errorf("unknown column function %s()", e.name)
+	}
+	return nil
+}
+
+func (e funcExpr) eval(hit elastic.SearchHit, document map[string]interface{}) interface{} {
+	switch e.name {
+	case "concat":
+		var sb strings.Builder
+		for _, arg := range e.args {
+			if val := arg.eval(hit, document); val != nil {
+				sb.WriteString(formatValue(val))
+			}
+		}
+		return sb.String()
+	case "len":
+		val := e.args[0].eval(hit, document)
+		if val == nil {
+			return nil
+		}
+		return utf8.RuneCountInString(formatValue(val))
+	case "date":
+		t, ok := parseTime(e.args[0].eval(hit, document))
+		if !ok {
+			return nil
+		}
+		return datePart(t, string(e.args[1].(constExpr)))
+	}
+	return nil
+}
+
+func (e funcExpr) fields() []string {
+	var fields []string
+	for _, arg := range e.args {
+		fields = append(fields, arg.fields()...)
+	}
+	return fields
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseTime converts a date value from a document to time.Time. Numbers are treated as epoch milliseconds.
+func parseTime(val interface{}) (time.Time, bool) {
+	switch val := val.(type) {
+	case float64:
+		return time.UnixMilli(int64(val)).UTC(), true
+	case json.Number:
+		if ms, err := val.Int64(); err == nil {
+			return time.UnixMilli(ms).UTC(), true
+		}
+	case int64:
+		return time.UnixMilli(val).UTC(), true
+	case string:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, val); err == nil {
+				return t, true
+			}
+		}
+		if ms, err := strconv.ParseInt(val, 10, 64); err == nil {
+			return time.UnixMilli(ms).UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// datePart returns a named part of t or formats t with part as Go time layout.
+func datePart(t time.Time, part string) interface{} {
+	switch part {
+	case "year":
+		return t.Year()
+	case "month":
+		return int(t.Month())
+	case "day":
+		return t.Day()
+	case "hour":
+		return t.Hour()
+	case "minute":
+		return t.Minute()
+	case "second":
+		return t.Second()
+	case "weekday":
+		return t.Weekday().String()
+	case "yearday":
+		return t.YearDay()
+	case "date":
+		return t.Format("2006-01-02")
+	case "time":
+		return t.Format("15:04:05")
+	}
+	return t.Format(part)
+}
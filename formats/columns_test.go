@@ -0,0 +1,138 @@
+package formats
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testHit struct {
+	source  []byte
+	fields  []byte
+	id      string
+	index   string
+	routing string
+}
+
+func (h testHit) GetSource() []byte  { return h.source }
+func (h testHit) GetFields() []byte  { return h.fields }
+func (h testHit) GetID() string      { return h.id }
+func (h testHit) GetIndex() string   { return h.index }
+func (h testHit) GetRouting() string { return h.routing }
+
+func TestParseColumns(t *testing.T) {
+	hit := testHit{id: "doc-1", index: "logs-2026"}
+	document := map[string]interface{}{
+		"@timestamp": "2026-10-17T13:45:00Z",
+		"user.first": "Jane",
+		"user.last":  "Doe",
+		"message":    "hello world",
+	}
+
+	tests := []struct {
+		name   string
+		spec   string
+		header []string
+		values []interface{}
+		fields []string
+	}{
+		{
+			"plain",
+			"message,user.first",
+			[]string{"message", "user.first"},
+			[]interface{}{"hello world", "Jane"},
+			[]string{"message", "user.first"},
+		},
+		{
+			"rename and metadata",
+			"ts=@timestamp,_id,idx=_index",
+			[]string{"ts", "_id", "idx"},
+			[]interface{}{"2026-10-17T13:45:00Z", "doc-1", "logs-2026"},
+			[]string{"@timestamp"},
+		},
+		{
+			"constant",
+			"env='prod'",
+			[]string{"env"},
+			[]interface{}{"prod"},
+			nil,
+		},
+		{
+			"functions",
+			"name=concat(user.first, ' ', user.last),day=date(@timestamp, 'date'),hour=date(@timestamp, 'hour'),msglen=len(message)",
+			[]string{"name", "day", "hour", "msglen"},
+			[]interface{}{"Jane Doe", "2026-10-17", 13, 11},
+			[]string{"user.first", "user.last", "@timestamp", "message"},
+		},
+		{
+			"missing field",
+			"missing,len(missing)",
+			[]string{"missing", "len(missing)"},
+			[]interface{}{nil, nil},
+			[]string{"missing"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, err := ParseColumns(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseColumns() error = %v", err)
+			}
+
+			if got := ColumnNames(columns); !reflect.DeepEqual(got, tt.header) {
+				t.Errorf("ColumnNames() = %v, want %v", got, tt.header)
+			}
+
+			var values []interface{}
+			for _, column := range columns {
+				values = append(values, column.Value(hit, document))
+			}
+			if !reflect.DeepEqual(values, tt.values) {
+				t.Errorf("Value() = %v, want %v", values, tt.values)
+			}
+
+			if got := SourceFields(columns); !reflect.DeepEqual(got, tt.fields) {
+				t.Errorf("SourceFields() = %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
+
+func TestParseColumnsErrors(t *testing.T) {
+	for _, spec := range []string{"x=unknown(a)", "len(a,b)", "date(a)", "x='open", "concat(a"} {
+		if _, err := ParseColumns(spec); err == nil {
+			t.Errorf("ParseColumns(%q) expected error", spec)
+		}
+	}
+}
+
+func TestExpandColumns(t *testing.T) {
+	names := []string{"message", "user.address.city", "user.id", "user.name"}
+
+	columns, err := ParseColumns("_id,user.*,msg=message,u=user.address.*,all=*")
+	if err != nil {
+		t.Fatalf("ParseColumns() error = %v", err)
+	}
+
+	want := []string{
+		"_id", "user.address.city", "user.id", "user.name", "msg", "u.city",
+		"all.message", "all.user.address.city", "all.user.id", "all.user.name",
+	}
+	expanded := ExpandColumns(columns, names)
+	if got := ColumnNames(expanded); !reflect.DeepEqual(got, want) {
+		t.Errorf("ExpandColumns() = %v, want %v", got, want)
+	}
+	if HasWildcards(expanded) || !HasWildcards(columns) {
+		t.Errorf("HasWildcards() did not detect the wildcard columns")
+	}
+
+	document := flatten(map[string]interface{}{
+		"user": map[string]interface{}{
+			"address": map[string]interface{}{
+				"city": "Berlin",
+			},
+		},
+	})
+	if got := ColumnNames(ExpandColumns(columns[3:4], documentFields(document))); !reflect.DeepEqual(got, []string{"u.city"}) {
+		t.Errorf("ExpandColumns() from document = %v", got)
+	}
+}
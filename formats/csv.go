@@ -5,105 +5,113 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
-	"log"
-	"os"
+	"io"
 	"regexp"
+	"strings"
 	"sync"
 
 	"golang.org/x/sync/errgroup"
 	"gopkg.in/cheggaaa/pb.v2"
 
 	"github.com/pteich/elastic-query-export/elastic"
-	"github.com/pteich/elastic-query-export/flags"
 )
 
+// CSV writes one row per document with a header row. Wildcard columns that were not expanded with
+// ExpandColumns are expanded from the first document.
 type CSV struct {
-	Conf       *flags.Flags
-	Outfile    *os.File
+	Columns    []Column
+	Outfile    io.Writer
 	Workers    int
 	ProgessBar *pb.ProgressBar
+	Warnings   *Warnings
 }
 
 func (c CSV) Run(ctx context.Context, hits <-chan elastic.SearchHit) error {
 	g, ctx := errgroup.WithContext(ctx)
 
 	csvout := make(chan []string, c.Workers)
-	defer close(csvout)
 
-	go func() {
+	// the first write error cancels the workers, so the export stops instead of reading all remaining hits
+	g.Go(func() error {
 		w := csv.NewWriter(c.Outfile)
+		// the header is always the first row
+		header := true
 
 		for csvdata := range csvout {
-			if err := w.Write(csvdata); err != nil {
-				log.Printf("Error writing CSV data - %v", err)
+			w.Write(csvdata)
+			w.Flush()
+			if err := w.Error(); err != nil {
+				return fmt.Errorf("writing CSV data - %w", err)
 			}
 
-			w.Flush()
-			c.ProgessBar.Increment()
+			if !header {
+				c.ProgessBar.Increment()
+			}
+			header = false
 		}
+		return nil
+	})
 
-	}()
+	send := func(csvdata []string) error {
+		select {
+		case csvout <- csvdata:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 
 	sendHeader := sync.Once{}
-	fields := c.Conf.Fields
+	var fields []string
+	var columns []Column
 	headerSent := make(chan struct{})
 
+	var workers sync.WaitGroup
 	for i := 0; i < c.Workers; i++ {
+		workers.Add(1)
 		g.Go(func() error {
+			defer workers.Done()
 
 			for hit := range hits {
 				var document map[string]interface{}
 				var csvdata []string
-				var outdata string
 
 				if err := json.Unmarshal(hit.GetSource(), &document); err != nil {
-					log.Printf("Error unmarshal JSON from ElasticSearch - %v", err)
+					c.Warnings.Skip(1, "Error unmarshal JSON from ElasticSearch - %v", err)
+					continue
 				}
 
 				document = flatten(document)
 
 				sendHeader.Do(func() {
-					if c.Conf.Fields == nil {
+					if c.Columns != nil {
+						columns = ExpandColumns(c.Columns, documentFields(document))
+						fields = ColumnNames(columns)
+					} else {
 						for key := range document {
 							fields = append(fields, key)
 						}
 					}
-					csvout <- fields
+					send(fields)
 					close(headerSent)
 				})
 
 				<-headerSent
 
-				for _, field := range fields {
-					if val, ok := document[field]; ok {
-						if val == nil {
-							csvdata = append(csvdata, "")
-							continue
-						}
-
-						// this type switch is probably not really needed anymore
-						switch val := val.(type) {
-						case int64:
-							outdata = fmt.Sprintf("%d", val)
-						case float64:
-							d := int(val)
-							if val == float64(d) {
-								outdata = fmt.Sprintf("%d", d)
-							} else {
-								outdata = fmt.Sprintf("%f", val)
-							}
-						default:
-							outdata = removeLBR(fmt.Sprintf("%v", val))
-						}
-
-						csvdata = append(csvdata, outdata)
-					} else {
-						csvdata = append(csvdata, "")
+				if c.Columns != nil {
+					for _, column := range columns {
+						csvdata = append(csvdata, formatValue(column.Value(hit, document)))
+					}
+				} else {
+					for _, field := range fields {
+						csvdata = append(csvdata, formatValue(document[field]))
 					}
 				}
 
 				// send string array to csv output
-				csvout <- csvdata
+				if err := send(csvdata); err != nil {
+					return err
+				}
 
 				select {
 				default:
@@ -115,9 +123,35 @@ func (c CSV) Run(ctx context.Context, hits <-chan elastic.SearchHit) error {
 		})
 	}
 
+	// the writer stops when all rows are sent, so the output can be closed safely after Wait
+	go func() {
+		workers.Wait()
+		close(csvout)
+	}()
+
 	return g.Wait()
 }
 
+func formatValue(val interface{}) string {
+	if val == nil {
+		return ""
+	}
+
+	// this type switch is probably not really needed anymore
+	switch val := val.(type) {
+	case int64:
+		return fmt.Sprintf("%d", val)
+	case float64:
+		d := int(val)
+		if val == float64(d) {
+			return fmt.Sprintf("%d", d)
+		}
+		return fmt.Sprintf("%f", val)
+	default:
+		return removeLBR(fmt.Sprintf("%v", val))
+	}
+}
+
 func flatten(document map[string]interface{}) map[string]interface{} {
 	result := map[string]interface{}{}
 
@@ -137,6 +171,38 @@ func flatten(document map[string]interface{}) map[string]interface{} {
 	return result
 }
 
+// unflatten turns dotted keys like "user.name" into nested objects, as they are returned by the fields API.
+func unflatten(document map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+
+	for key, value := range document {
+		parts := strings.Split(key, ".")
+		current := result
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := current[part].(map[string]interface{})
+			if !ok {
+				child = map[string]interface{}{}
+				current[part] = child
+			}
+			current = child
+		}
+
+		last := parts[len(parts)-1]
+		if childDocument, ok := value.(map[string]interface{}); ok {
+			if existing, ok := current[last].(map[string]interface{}); ok {
+				for subKey, subValue := range unflatten(childDocument) {
+					existing[subKey] = subValue
+				}
+				continue
+			}
+			value = unflatten(childDocument)
+		}
+		current[last] = value
+	}
+
+	return result
+}
+
 func removeLBR(text string) string {
 	re := regexp.MustCompile(`\x{000D}\x{000A}|[\x{000A}\x{000B}\x{000C}\x{000D}\x{0085}\x{2028}\x{2029}]`)
 	return re.ReplaceAllString(text, ``)
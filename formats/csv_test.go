@@ -1,10 +1,71 @@
 package formats
 
 import (
+	"bytes"
+	"context"
 	"reflect"
 	"testing"
+
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
 )
 
+func TestCSVSkipsInvalidJSON(t *testing.T) {
+	columns, err := ParseColumns("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hits := make(chan elastic.SearchHit, 3)
+	for _, source := range []string{`{"a":1}`, `invalid`, `{"a":2}`} {
+		hits <- testHit{source: []byte(source)}
+	}
+	close(hits)
+
+	var buf bytes.Buffer
+	bar := pb.New(3)
+	warnings := &Warnings{}
+	c := CSV{Columns: columns, Outfile: &buf, Workers: 1, ProgessBar: bar, Warnings: warnings}
+	if err := c.Run(context.Background(), hits); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if buf.String() != "a\n1\n2\n" {
+		t.Errorf("Run() wrote %q", buf.String())
+	}
+	if bar.Current() != 2 || warnings.Skipped() != 1 || len(warnings.Messages()) != 1 {
+		t.Errorf("written %d, skipped %d, warnings %v", bar.Current(), warnings.Skipped(), warnings.Messages())
+	}
+}
+
+func TestCSVWriteError(t *testing.T) {
+	columns, err := ParseColumns("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hits := make(chan elastic.SearchHit, 100)
+	for i := 0; i < cap(hits); i++ {
+		hits <- testHit{source: []byte(`{"a":1}`)}
+	}
+	close(hits)
+
+	bar := pb.New(cap(hits))
+	c := CSV{Columns: columns, Outfile: failingWriter{}, Workers: 2, ProgessBar: bar}
+	if err := c.Run(context.Background(), hits); err == nil {
+		t.Fatal("Run() expected write error")
+	}
+
+	// the workers stop after the first failed write instead of reading all hits
+	if len(hits) < cap(hits)/2 {
+		t.Errorf("Run() read %d of %d hits after the write error", cap(hits)-len(hits), cap(hits))
+	}
+	if bar.Current() != 0 {
+		t.Errorf("Run() counted %d documents that were not written", bar.Current())
+	}
+}
+
 func Test_flatten(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -119,3 +180,29 @@ func Test_flatten(t *testing.T) {
 		})
 	}
 }
+
+func Test_unflatten(t *testing.T) {
+	document := map[string]interface{}{
+		"message":          "hello",
+		"user.name":        "jane",
+		"user.address.zip": "10115",
+		"user": map[string]interface{}{
+			"id": 1.0,
+		},
+	}
+
+	want := map[string]interface{}{
+		"message": "hello",
+		"user": map[string]interface{}{
+			"id":   1.0,
+			"name": "jane",
+			"address": map[string]interface{}{
+				"zip": "10115",
+			},
+		},
+	}
+
+	if got := unflatten(document); !reflect.DeepEqual(got, want) {
+		t.Errorf("unflatten() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,21 @@
+package formats
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// FileExt returns the extension of path including the compression and encryption extensions,
+// like .csv.gz.age.
+func FileExt(path string) string {
+	var ext string
+	for {
+		e := filepath.Ext(strings.TrimSuffix(path, ext))
+		ext = e + ext
+		switch e {
+		case ".age", ".gz", ".zst", ".zstd":
+			continue
+		}
+		return ext
+	}
+}
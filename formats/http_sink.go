@@ -0,0 +1,217 @@
+package formats
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+// HTTPSink posts the documents in batches of BatchSize to URL, as NDJSON or with Style array or pretty
+// as JSON array. Workers batches are sent concurrently, batches that fail with 429 or a 5xx status are
+// retried with backoff. Batches that still fail are logged with the response and skipped.
+type HTTPSink struct {
+	Client     *http.Client
+	URL        string
+	Headers    http.Header
+	User       string
+	Pass       string
+	Token      string
+	ProgessBar *pb.ProgressBar
+	Warnings   *Warnings
+	Style      string
+	Columns    []Column
+	BatchSize  int
+	Workers    int
+	MaxRetries int
+	// Retries counts the retried requests, if set
+	Retries *atomic.Int64
+}
+
+func (s HTTPSink) Run(ctx context.Context, hits <-chan elastic.SearchHit) error {
+	switch s.Style {
+	case "", JSONStyleNDJSON, JSONStyleArray, JSONStylePretty:
+	default:
+		return fmt.Errorf("unknown json style %s", s.Style)
+	}
+
+	batchSize := s.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	workers := s.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	formatter := JSON{Columns: s.Columns}
+	batches := make(chan [][]byte, workers)
+	failedMu := sync.Mutex{}
+	failed := 0
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	for i := 0; i < workers; i++ {
+		g.Go(func() error {
+			for batch := range batches {
+				if err := s.send(ctx, batch); err != nil {
+					if ctx.Err() != nil {
+						return ctx.Err()
+					}
+					s.Warnings.Logf("Failed to send %d documents - %v", len(batch), err)
+					failedMu.Lock()
+					failed += len(batch)
+					failedMu.Unlock()
+					continue
+				}
+
+				s.ProgessBar.Add(len(batch))
+			}
+			return nil
+		})
+	}
+
+	g.Go(func() error {
+		defer close(batches)
+
+		batch := make([][]byte, 0, batchSize)
+		for hit := range hits {
+			data, err := formatter.document(hit)
+			if err != nil {
+				s.Warnings.Skip(1, "Error unmarshal JSON from ElasticSearch - %v", err)
+				continue
+			}
+
+			batch = append(batch, data)
+			if len(batch) < batchSize {
+				continue
+			}
+
+			select {
+			case batches <- batch:
+				batch = make([][]byte, 0, batchSize)
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if len(batch) > 0 {
+			select {
+			case batches <- batch:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		return nil
+	})
+
+	err := g.Wait()
+	if failed > 0 {
+		s.Warnings.Skip(failed, "%d documents could not be sent", failed)
+	}
+
+	return err
+}
+
+// send posts a batch and retries it with backoff while the server answers with 429 or a 5xx status.
+func (s HTTPSink) send(ctx context.Context, documents [][]byte) error {
+	body, contentType, err := s.body(documents)
+	if err != nil {
+		return err
+	}
+
+	maxRetries := s.MaxRetries
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	backoff := 500 * time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+		retryable, err := s.post(ctx, body, contentType)
+		if err == nil || !retryable || attempt >= maxRetries {
+			return err
+		}
+
+		if s.Retries != nil {
+			s.Retries.Add(1)
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// body encodes the documents of a batch in the configured style.
+func (s HTTPSink) body(documents [][]byte) ([]byte, string, error) {
+	var buf bytes.Buffer
+
+	style := s.Style
+	if style == "" {
+		style = JSONStyleNDJSON
+	}
+
+	for i, data := range documents {
+		if err := writeJSON(&buf, style, data, i == 0); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if style == JSONStyleNDJSON {
+		return buf.Bytes(), "application/x-ndjson", nil
+	}
+	buf.WriteString("\n]\n")
+	return buf.Bytes(), "application/json", nil
+}
+
+// post sends a batch. Network errors, 429 and 5xx responses are reported as retryable.
+func (s HTTPSink) post(ctx context.Context, body []byte, contentType string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for name, values := range s.Headers {
+		req.Header[name] = values
+	}
+	switch {
+	case s.Token != "":
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	case s.User != "":
+		req.SetBasicAuth(s.User, s.Pass)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		response, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		err := fmt.Errorf("request failed with status %d: %s", resp.StatusCode, response)
+		return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+	}
+
+	// read the response so the connection can be reused
+	_, err = io.Copy(io.Discard, resp.Body)
+	return false, err
+}
@@ -0,0 +1,119 @@
+package formats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+func TestHTTPSink(t *testing.T) {
+	mu := sync.Mutex{}
+	received := map[int]int{}
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("X-Source") != "export" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests++
+		if requests == 1 {
+			// fail the first request to make the sink retry
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var documents []map[string]int
+		if err := json.NewDecoder(r.Body).Decode(&documents); err != nil {
+			t.Errorf("body is no JSON array: %v", err)
+		}
+		for _, document := range documents {
+			if document["n"] == 5 {
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, `{"error":"invalid document"}`)
+				return
+			}
+		}
+		for _, document := range documents {
+			received[document["n"]]++
+		}
+	}))
+	defer server.Close()
+
+	hits := make(chan elastic.SearchHit, 5)
+	for i := 1; i <= 5; i++ {
+		hits <- testHit{source: []byte(fmt.Sprintf(`{"n":%d}`, i))}
+	}
+	close(hits)
+
+	s := HTTPSink{
+		URL:        server.URL,
+		Headers:    http.Header{"X-Source": []string{"export"}},
+		Token:      "token",
+		ProgessBar: pb.New(5),
+		Style:      JSONStyleArray,
+		BatchSize:  2,
+		Workers:    1,
+		MaxRetries: 2,
+		Retries:    &atomic.Int64{},
+	}
+	if err := s.Run(context.Background(), hits); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// the last batch with document 5 is rejected and skipped
+	for n := 1; n <= 4; n++ {
+		if received[n] != 1 {
+			t.Errorf("document %d received %d times", n, received[n])
+		}
+	}
+	if received[5] != 0 {
+		t.Errorf("rejected document 5 was received")
+	}
+	if retries := s.Retries.Load(); retries != 1 {
+		t.Errorf("counted %d retries, want 1", retries)
+	}
+}
+
+func TestHTTPSinkBody(t *testing.T) {
+	documents := [][]byte{[]byte(`{"n":1}`), []byte(`{"n":2}`)}
+
+	tests := []struct {
+		style       string
+		want        string
+		contentType string
+	}{
+		{JSONStyleNDJSON, "{\"n\":1}\n{\"n\":2}\n", "application/x-ndjson"},
+		{JSONStyleArray, "[\n{\"n\":1},\n{\"n\":2}\n]\n", "application/json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			body, contentType, err := HTTPSink{Style: tt.style}.body(documents)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != tt.want || contentType != tt.contentType {
+				t.Errorf("body() = %q, %s, want %q, %s", body, contentType, tt.want, tt.contentType)
+			}
+		})
+	}
+}
@@ -1,23 +1,58 @@
 package formats
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
-	"os"
+	"io"
 
 	"gopkg.in/cheggaaa/pb.v2"
 
 	"github.com/pteich/elastic-query-export/elastic"
 )
 
+// JSON styles that can be written by the JSON format.
+const (
+	JSONStyleNDJSON = "ndjson"
+	JSONStyleArray  = "array"
+	JSONStylePretty = "pretty"
+)
+
+// JSON writes one document per line, or with Style array or pretty a single JSON array that is
+// streamed document by document. If Columns are given, every document is built from the columns
+// with dotted names turned into nested objects. Wildcard columns that were not expanded with ExpandColumns
+// are expanded for every document.
 type JSON struct {
-	Outfile    *os.File
+	Outfile    io.Writer
 	ProgessBar *pb.ProgressBar
+	Warnings   *Warnings
+	Style      string
+	Columns    []Column
 }
 
 func (j JSON) Run(ctx context.Context, hits <-chan elastic.SearchHit) error {
+	style := j.Style
+	switch style {
+	case "":
+		style = JSONStyleNDJSON
+	case JSONStyleNDJSON, JSONStyleArray, JSONStylePretty:
+	default:
+		return fmt.Errorf("unknown json style %s", style)
+	}
+
+	count := 0
 	for hit := range hits {
-		fmt.Fprintln(j.Outfile, string(hit.GetSource()))
+		data, err := j.document(hit)
+		if err != nil {
+			j.Warnings.Skip(1, "Error unmarshal JSON from ElasticSearch - %v", err)
+			continue
+		}
+
+		if err := writeJSON(j.Outfile, style, data, count == 0); err != nil {
+			return err
+		}
+		count++
 		j.ProgessBar.Increment()
 
 		select {
@@ -27,5 +62,64 @@ func (j JSON) Run(ctx context.Context, hits <-chan elastic.SearchHit) error {
 		}
 	}
 
-	return nil
+	switch {
+	case style == JSONStyleNDJSON:
+		return nil
+	case count == 0:
+		_, err := fmt.Fprintln(j.Outfile, "[]")
+		return err
+	default:
+		_, err := fmt.Fprint(j.Outfile, "\n]\n")
+		return err
+	}
+}
+
+// document returns the JSON of a hit, either its source or the document built from the columns.
+func (j JSON) document(hit elastic.SearchHit) ([]byte, error) {
+	if j.Columns == nil {
+		return hit.GetSource(), nil
+	}
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(hit.GetSource(), &document); err != nil {
+		return nil, err
+	}
+	document = flatten(document)
+
+	projected := make(map[string]interface{})
+	for _, column := range ExpandColumns(j.Columns, documentFields(document)) {
+		projected[column.Name] = column.Value(hit, document)
+	}
+
+	return json.Marshal(unflatten(projected))
+}
+
+func writeJSON(w io.Writer, style string, data []byte, first bool) error {
+	var err error
+
+	switch style {
+	case JSONStyleArray:
+		separator := ",\n"
+		if first {
+			separator = "[\n"
+		}
+		if _, err = io.WriteString(w, separator); err == nil {
+			_, err = w.Write(data)
+		}
+	case JSONStylePretty:
+		separator := ",\n  "
+		if first {
+			separator = "[\n  "
+		}
+		var indented bytes.Buffer
+		if err = json.Indent(&indented, data, "  ", "  "); err == nil {
+			if _, err = io.WriteString(w, separator); err == nil {
+				_, err = indented.WriteTo(w)
+			}
+		}
+	default:
+		_, err = fmt.Fprintln(w, string(data))
+	}
+
+	return err
 }
@@ -0,0 +1,71 @@
+package formats
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		style   string
+		columns string
+		hits    []string
+		want    string
+	}{
+		{"ndjson", "", "", []string{`{"a":1}`, `{"a":2}`}, "{\"a\":1}\n{\"a\":2}\n"},
+		{"array", JSONStyleArray, "", []string{`{"a":1}`, `{"a":2}`}, "[\n{\"a\":1},\n{\"a\":2}\n]\n"},
+		{"empty array", JSONStyleArray, "", nil, "[]\n"},
+		{"pretty", JSONStylePretty, "", []string{`{"a":1}`, `{"a":2}`}, "[\n  {\n    \"a\": 1\n  },\n  {\n    \"a\": 2\n  }\n]\n"},
+		{
+			"columns",
+			"",
+			"user.name,id=_id,host.*",
+			[]string{`{"user":{"name":"jane","age":42},"host":{"ip":"10.0.0.1","os":"linux"}}`},
+			`{"host":{"ip":"10.0.0.1","os":"linux"},"id":"doc-1","user":{"name":"jane"}}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out.json")
+			outfile, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer outfile.Close()
+
+			var columns []Column
+			if tt.columns != "" {
+				if columns, err = ParseColumns(tt.columns); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			hits := make(chan elastic.SearchHit, len(tt.hits))
+			for _, source := range tt.hits {
+				hits <- testHit{source: []byte(source), id: "doc-1"}
+			}
+			close(hits)
+
+			j := JSON{Outfile: outfile, ProgessBar: pb.New(len(tt.hits)), Style: tt.style, Columns: columns}
+			if err := j.Run(context.Background(), hits); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Run() wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
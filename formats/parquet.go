@@ -0,0 +1,185 @@
+package formats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/compress"
+	"github.com/parquet-go/parquet-go/compress/gzip"
+	"github.com/parquet-go/parquet-go/compress/snappy"
+	"github.com/parquet-go/parquet-go/compress/uncompressed"
+	"github.com/parquet-go/parquet-go/compress/zstd"
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+// defaultRowGroupSize is used if no RowGroupSize is set, the parquet library would otherwise keep all
+// rows in memory until the file is closed.
+const defaultRowGroupSize = 10000
+
+// Parquet writes all documents into a Parquet file with a schema derived from the index mapping.
+// Objects become optional groups and nested fields repeated groups, fields marked as Array in the mapping
+// are repeated as well. Rows are flushed in row groups of RowGroupSize documents so memory usage stays bounded.
+type Parquet struct {
+	Outfile      io.Writer
+	ProgessBar   *pb.ProgressBar
+	Warnings     *Warnings
+	Mapping      []elastic.Field
+	RowGroupSize int
+	Compression  string
+}
+
+func (p Parquet) Run(ctx context.Context, hits <-chan elastic.SearchHit) error {
+	if len(p.Mapping) == 0 {
+		return errors.New("no fields found in index mapping")
+	}
+
+	codec, err := parquetCodec(p.Compression)
+	if err != nil {
+		return err
+	}
+
+	rowGroupSize := p.RowGroupSize
+	if rowGroupSize <= 0 {
+		rowGroupSize = defaultRowGroupSize
+	}
+
+	w := parquet.NewWriter(p.Outfile,
+		parquet.NewSchema("document", parquetGroup(p.Mapping)),
+		parquet.Compression(codec),
+		parquet.MaxRowsPerRowGroup(int64(rowGroupSize)),
+	)
+	arrays := newArrayWarnings(p.Warnings)
+
+	for hit := range hits {
+		document, err := decodeDocument(hit.GetSource())
+		if err != nil {
+			p.Warnings.Skip(1, "Error unmarshal JSON from ElasticSearch - %v", err)
+			continue
+		}
+
+		if err := w.Write(parquetRow(p.Mapping, unflatten(document), arrays, "")); err != nil {
+			return err
+		}
+		p.ProgessBar.Increment()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+
+	return w.Close()
+}
+
+func parquetCodec(name string) (compress.Codec, error) {
+	switch name {
+	case "", "snappy":
+		return &snappy.Codec{}, nil
+	case "zstd":
+		return &zstd.Codec{}, nil
+	case "gzip":
+		return &gzip.Codec{}, nil
+	case "none":
+		return &uncompressed.Codec{}, nil
+	default:
+		return nil, fmt.Errorf("unknown parquet compression %s", name)
+	}
+}
+
+func parquetGroup(fields []elastic.Field) parquet.Group {
+	group := parquet.Group{}
+	for _, field := range fields {
+		group[field.Name] = parquetNode(field)
+	}
+	return group
+}
+
+func parquetNode(field elastic.Field) parquet.Node {
+	// objects without known sub fields are stored as JSON text
+	if field.IsGroup() && len(field.Fields) > 0 {
+		if field.Type == elastic.TypeNested || field.Array {
+			return parquet.Repeated(parquetGroup(field.Fields))
+		}
+		return parquet.Optional(parquetGroup(field.Fields))
+	}
+
+	if field.Array {
+		return parquet.Repeated(parquetLeaf(field))
+	}
+	return parquet.Optional(parquetLeaf(field))
+}
+
+func parquetLeaf(field elastic.Field) parquet.Node {
+	switch kindOf(field.Type) {
+	case kindInt:
+		return parquet.Int(64)
+	case kindFloat:
+		return parquet.Leaf(parquet.DoubleType)
+	case kindBool:
+		return parquet.Leaf(parquet.BooleanType)
+	case kindTime:
+		if field.Type == "date_nanos" {
+			return parquet.Timestamp(parquet.Nanosecond)
+		}
+		return parquet.Timestamp(parquet.Millisecond)
+	default:
+		return parquet.String()
+	}
+}
+
+// parquetRow converts a document to a row matching the schema of the given fields. Fields are named
+// with their prefix in warnings about arrays.
+func parquetRow(fields []elastic.Field, document map[string]interface{}, arrays *arrayWarnings, prefix string) map[string]interface{} {
+	row := make(map[string]interface{}, len(fields))
+
+	for _, field := range fields {
+		val, ok := document[field.Name]
+		if !ok || val == nil {
+			continue
+		}
+
+		if !field.IsGroup() || len(field.Fields) == 0 {
+			kind := kindOf(field.Type)
+			if field.Array {
+				row[field.Name] = convertValues(kind, val)
+			} else {
+				arrays.check(prefix+field.Name, kind, val)
+				row[field.Name] = convertValue(kind, val)
+			}
+			continue
+		}
+
+		var objects []interface{}
+		switch val := val.(type) {
+		case map[string]interface{}:
+			objects = []interface{}{val}
+		case []interface{}:
+			objects = val
+		}
+
+		var groups []interface{}
+		for _, object := range objects {
+			if object, ok := object.(map[string]interface{}); ok {
+				groups = append(groups, parquetRow(field.Fields, object, arrays, prefix+field.Name+"."))
+			}
+		}
+
+		switch {
+		case field.Type == elastic.TypeNested || field.Array:
+			row[field.Name] = groups
+		case len(groups) > 0:
+			if len(groups) > 1 {
+				arrays.truncated(prefix + field.Name)
+			}
+			row[field.Name] = groups[0]
+		}
+	}
+
+	return row
+}
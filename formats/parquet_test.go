@@ -0,0 +1,174 @@
+package formats
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+func TestParquet(t *testing.T) {
+	mapping := []elastic.Field{
+		{Name: "@timestamp", Type: "date"},
+		{Name: "bytes", Type: "long"},
+		{Name: "tags", Type: elastic.TypeNested, Fields: []elastic.Field{{Name: "key", Type: "keyword"}}},
+		{Name: "user", Type: elastic.TypeObject, Fields: []elastic.Field{{Name: "name", Type: "keyword"}}},
+	}
+
+	outfile, err := os.Create(filepath.Join(t.TempDir(), "out.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outfile.Close()
+
+	hits := make(chan elastic.SearchHit, 2)
+	hits <- testHit{source: []byte(`{"@timestamp":"2026-10-17T13:45:00Z","bytes":512,"user":{"name":"jane"},"tags":[{"key":"a"},{"key":"b"}]}`)}
+	hits <- testHit{source: []byte(`{"user.name":"joe","bytes":"1024"}`)}
+	close(hits)
+
+	p := Parquet{
+		Outfile:      outfile,
+		ProgessBar:   pb.New(2),
+		Mapping:      mapping,
+		RowGroupSize: 1,
+		Compression:  "zstd",
+	}
+	if err := p.Run(context.Background(), hits); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	stat, err := outfile.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := parquet.OpenFile(outfile, stat.Size())
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	if got := len(file.RowGroups()); got != 2 {
+		t.Errorf("expected 2 row groups, got %d", got)
+	}
+
+	reader := parquet.NewReader(file)
+	var rows []map[string]interface{}
+	for i := 0; i < 2; i++ {
+		row := map[string]interface{}{}
+		if err := reader.Read(&row); err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+		rows = append(rows, row)
+	}
+
+	if rows[0]["bytes"] != int64(512) || rows[1]["bytes"] != int64(1024) {
+		t.Errorf("unexpected bytes values %v and %v", rows[0]["bytes"], rows[1]["bytes"])
+	}
+	if tags, _ := rows[0]["tags"].([]interface{}); len(tags) != 2 {
+		t.Errorf("expected 2 nested tags, got %v", rows[0]["tags"])
+	}
+	if user, _ := rows[1]["user"].(map[string]interface{}); user["name"] != "joe" {
+		t.Errorf("expected unflattened user.name, got %v", rows[1]["user"])
+	}
+}
+
+func TestParquetDefaultRowGroupSize(t *testing.T) {
+	outfile, err := os.Create(filepath.Join(t.TempDir(), "out.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outfile.Close()
+
+	hits := make(chan elastic.SearchHit, defaultRowGroupSize+1)
+	for i := 0; i <= defaultRowGroupSize; i++ {
+		hits <- testHit{source: []byte(`{"n":1}`)}
+	}
+	close(hits)
+
+	p := Parquet{Outfile: outfile, ProgessBar: pb.New(defaultRowGroupSize + 1), Mapping: []elastic.Field{{Name: "n", Type: "long"}}}
+	if err := p.Run(context.Background(), hits); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	stat, err := outfile.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := parquet.OpenFile(outfile, stat.Size())
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+
+	// without a row group size rows are not kept in memory until the file is closed
+	if got := len(file.RowGroups()); got != 2 {
+		t.Errorf("expected 2 row groups, got %d", got)
+	}
+}
+
+func TestParquetArrays(t *testing.T) {
+	mapping := []elastic.Field{
+		{Name: "bytes", Type: "long"},
+		{Name: "hosts", Type: elastic.TypeObject, Fields: []elastic.Field{{Name: "name", Type: "keyword"}}, Array: true},
+		{Name: "labels", Type: "keyword", Array: true},
+		{Name: "ports", Type: "long", Array: true},
+	}
+
+	outfile, err := os.Create(filepath.Join(t.TempDir(), "out.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outfile.Close()
+
+	hits := make(chan elastic.SearchHit, 2)
+	hits <- testHit{source: []byte(`{"bytes":[1,2],"ports":[80,443],"labels":"web","hosts":[{"name":"a"},{"name":"b"}]}`)}
+	hits <- testHit{source: []byte(`{"bytes":[3,4]}`)}
+	close(hits)
+
+	var logs strings.Builder
+	p := Parquet{
+		Outfile:    outfile,
+		ProgessBar: pb.New(2),
+		Warnings:   &Warnings{Logger: log.New(&logs, "", 0)},
+		Mapping:    mapping,
+	}
+	if err := p.Run(context.Background(), hits); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// arrays in columns that are not repeated are reported once
+	if got := strings.Count(logs.String(), "Field bytes holds arrays"); got != 1 {
+		t.Errorf("expected one warning about bytes, got %q", logs.String())
+	}
+
+	stat, err := outfile.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := parquet.OpenFile(outfile, stat.Size())
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+
+	row := map[string]interface{}{}
+	if err := parquet.NewReader(file).Read(&row); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+
+	if ports, _ := row["ports"].([]interface{}); len(ports) != 2 || ports[1] != int64(443) {
+		t.Errorf("expected repeated ports, got %v", row["ports"])
+	}
+	if labels, _ := row["labels"].([]interface{}); len(labels) != 1 || labels[0] != "web" {
+		t.Errorf("expected single value as repeated labels, got %v", row["labels"])
+	}
+	if hosts, _ := row["hosts"].([]interface{}); len(hosts) != 2 {
+		t.Errorf("expected repeated hosts, got %v", row["hosts"])
+	}
+	if row["bytes"] != int64(1) {
+		t.Errorf("expected first bytes value, got %v", row["bytes"])
+	}
+}
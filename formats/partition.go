@@ -0,0 +1,98 @@
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+// partitionDefault is the directory value of documents without a value for a partition key, as used by Hive.
+const partitionDefault = "__HIVE_DEFAULT_PARTITION__"
+
+// partitionLayouts are the Go time layouts for the supported date partition granularities.
+var partitionLayouts = map[string]string{
+	"year":  "2006",
+	"month": "2006-01",
+	"day":   "2006-01-02",
+	"hour":  "2006-01-02T15",
+}
+
+// PartitionKey is a field documents are partitioned by. With a date granularity like day
+// the directory is named after the granularity, e.g. day=2026-10-17, otherwise after the field.
+type PartitionKey struct {
+	Field       string
+	Granularity string
+}
+
+// ParsePartitionKeys parses a comma separated list of partition keys like "@timestamp:day,host".
+func ParsePartitionKeys(spec string) ([]PartitionKey, error) {
+	var keys []PartitionKey
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		field, granularity, _ := strings.Cut(item, ":")
+		if granularity != "" {
+			if _, ok := partitionLayouts[granularity]; !ok {
+				return nil, fmt.Errorf("unknown date granularity %s in partition key %s, use year, month, day or hour", granularity, item)
+			}
+		}
+		keys = append(keys, PartitionKey{Field: field, Granularity: granularity})
+	}
+
+	return keys, nil
+}
+
+func (k PartitionKey) name() string {
+	if k.Granularity != "" {
+		return k.Granularity
+	}
+	return k.Field
+}
+
+// PartitionDir returns the relative directory of a hit like "day=2026-10-17/host=web1".
+func PartitionDir(keys []PartitionKey, hit elastic.SearchHit) (string, error) {
+	var document map[string]interface{}
+	if err := json.Unmarshal(hit.GetSource(), &document); err != nil {
+		return "", err
+	}
+	document = flatten(document)
+
+	dirs := make([]string, len(keys))
+	for i, key := range keys {
+		value := partitionDefault
+
+		if val := document[key.Field]; val != nil {
+			if key.Granularity != "" {
+				if t, ok := parseTime(val); ok {
+					value = t.UTC().Format(partitionLayouts[key.Granularity])
+				}
+			} else if s := formatValue(val); s != "" {
+				value = escapePartition(s)
+			}
+		}
+
+		dirs[i] = escapePartition(key.name()) + "=" + value
+	}
+
+	return path.Join(dirs...), nil
+}
+
+// escapePartition escapes characters that are not allowed in partition directory names like Hive does.
+func escapePartition(s string) string {
+	var sb strings.Builder
+	for _, c := range []byte(s) {
+		if c < 0x20 || c == 0x7f || strings.IndexByte("\"#%'*/:=?\\[]^{}", c) >= 0 {
+			fmt.Fprintf(&sb, "%%%02X", c)
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
@@ -0,0 +1,52 @@
+package formats
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePartitionKeys(t *testing.T) {
+	keys, err := ParsePartitionKeys("@timestamp:day, host")
+	if err != nil {
+		t.Fatalf("ParsePartitionKeys() error = %v", err)
+	}
+	want := []PartitionKey{{Field: "@timestamp", Granularity: "day"}, {Field: "host"}}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("ParsePartitionKeys() = %v, want %v", keys, want)
+	}
+
+	if _, err := ParsePartitionKeys("@timestamp:week"); err == nil {
+		t.Error("expected error for unknown granularity")
+	}
+}
+
+func TestPartitionDir(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   string
+		source string
+		want   string
+	}{
+		{"day and host", "@timestamp:day,host", `{"@timestamp":"2026-10-17T13:45:00Z","host":"web1"}`, "day=2026-10-17/host=web1"},
+		{"hour from epoch millis", "@timestamp:hour", `{"@timestamp":1792244700000}`, "hour=2026-10-17T13"},
+		{"nested field", "host.name", `{"host":{"name":"web1"}}`, "host.name=web1"},
+		{"missing value", "host", `{}`, "host=__HIVE_DEFAULT_PARTITION__"},
+		{"escaped value", "path", `{"path":"/var/log"}`, "path=%2Fvar%2Flog"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := ParsePartitionKeys(tt.keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := PartitionDir(keys, testHit{source: []byte(tt.source)})
+			if err != nil {
+				t.Fatalf("PartitionDir() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("PartitionDir() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
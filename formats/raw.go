@@ -1,32 +1,44 @@
 package formats
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
-	"log"
-	"os"
+	"io"
 
 	"gopkg.in/cheggaaa/pb.v2"
 
 	"github.com/pteich/elastic-query-export/elastic"
 )
 
+// Raw writes the source of every document as it is stored, compacted to one JSON object per line.
 type Raw struct {
-	Outfile    *os.File
+	Outfile    io.Writer
 	ProgessBar *pb.ProgressBar
+	Warnings   *Warnings
 }
 
 func (r Raw) Run(ctx context.Context, hits <-chan elastic.SearchHit) error {
+	var data bytes.Buffer
+
 	for hit := range hits {
-		data, err := json.Marshal(hit)
-		if err != nil {
-			log.Println(err)
+		data.Reset()
+		if err := json.Compact(&data, hit.GetSource()); err != nil {
+			r.Warnings.Skip(1, "Error unmarshal JSON from ElasticSearch - %v", err)
 			continue
 		}
+		data.WriteByte('\n')
 
-		fmt.Fprintln(r.Outfile, string(data))
+		if _, err := r.Outfile.Write(data.Bytes()); err != nil {
+			return err
+		}
 		r.ProgessBar.Increment()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
 	}
 
 	return nil
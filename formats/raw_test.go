@@ -0,0 +1,57 @@
+package formats
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+// failingWriter fails every write like a full disk or a closed pipe.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("no space left on device")
+}
+
+func TestRaw(t *testing.T) {
+	hits := make(chan elastic.SearchHit, 3)
+	hits <- testHit{id: "1", index: "logs", source: []byte(`{"a":1}`)}
+	hits <- testHit{id: "2", index: "logs", source: []byte(`invalid`)}
+	hits <- testHit{id: "3", index: "logs", source: []byte("{\n  \"a\": 2\n}")}
+	close(hits)
+
+	var buf bytes.Buffer
+	bar := pb.New(3)
+	warnings := &Warnings{}
+	if err := (Raw{Outfile: &buf, ProgessBar: bar, Warnings: warnings}).Run(context.Background(), hits); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// sources stored with line breaks are written on a single line
+	if want := "{\"a\":1}\n{\"a\":2}\n"; buf.String() != want {
+		t.Errorf("Run() wrote %q, want %q", buf.String(), want)
+	}
+	if bar.Current() != 2 || warnings.Skipped() != 1 {
+		t.Errorf("written %d, skipped %d", bar.Current(), warnings.Skipped())
+	}
+}
+
+func TestRawWriteError(t *testing.T) {
+	hits := make(chan elastic.SearchHit, 1)
+	hits <- testHit{source: []byte(`{"a":1}`)}
+	close(hits)
+
+	bar := pb.New(1)
+	err := (Raw{Outfile: failingWriter{}, ProgessBar: bar}).Run(context.Background(), hits)
+	if err == nil {
+		t.Fatal("Run() expected write error")
+	}
+	if bar.Current() != 0 {
+		t.Errorf("Run() counted %d documents that were not written", bar.Current())
+	}
+}
@@ -0,0 +1,253 @@
+package formats
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/cheggaaa/pb.v2"
+	_ "modernc.org/sqlite"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+// SQLite writes all documents into a table of a SQLite database with one column per leaf field of the
+// index mapping. Rows are inserted in transactions of BatchSize documents. Without Append an existing
+// table is replaced, with Append the documents are added if the table has all needed columns.
+type SQLite struct {
+	Path       string
+	ProgessBar *pb.ProgressBar
+	Warnings   *Warnings
+	Mapping    []elastic.Field
+	Table      string
+	Indexes    []string
+	Append     bool
+	BatchSize  int
+}
+
+type sqliteColumn struct {
+	name    string
+	sqlType string
+	kind    valueKind
+}
+
+func (s SQLite) Run(ctx context.Context, hits <-chan elastic.SearchHit) error {
+	fields := elastic.LeafFields(s.Mapping)
+	if len(fields) == 0 {
+		return errors.New("no fields found in index mapping")
+	}
+
+	columns := make([]sqliteColumn, len(fields))
+	for i, field := range fields {
+		columns[i] = sqliteColumn{name: field.Name, kind: kindOf(field.Type), sqlType: sqliteType(kindOf(field.Type))}
+	}
+
+	for _, index := range s.Indexes {
+		if !hasSQLiteColumn(columns, index) {
+			return fmt.Errorf("can not create index on unknown column %s", index)
+		}
+	}
+
+	batchSize := s.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	db, err := sql.Open("sqlite", s.Path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := s.prepareTable(ctx, db, columns); err != nil {
+		return err
+	}
+
+	names := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = sqliteQuote(column.name)
+		placeholders[i] = "?"
+	}
+	insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		sqliteQuote(s.Table), strings.Join(names, ", "), strings.Join(placeholders, ", "))
+
+	var tx *sql.Tx
+	var stmt *sql.Stmt
+	rows := 0
+
+	commit := func() error {
+		if tx == nil {
+			return nil
+		}
+		stmt.Close()
+		err := tx.Commit()
+		tx, rows = nil, 0
+		return err
+	}
+	defer func() {
+		if tx != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for hit := range hits {
+		document, err := decodeDocument(hit.GetSource())
+		if err != nil {
+			s.Warnings.Skip(1, "Error unmarshal JSON from ElasticSearch - %v", err)
+			continue
+		}
+
+		if tx == nil {
+			if tx, err = db.BeginTx(ctx, nil); err != nil {
+				return err
+			}
+			if stmt, err = tx.PrepareContext(ctx, insert); err != nil {
+				return err
+			}
+		}
+
+		document = flatten(document)
+		values := make([]interface{}, len(columns))
+		for i, column := range columns {
+			values[i] = sqliteValue(convertValue(column.kind, document[column.name]))
+		}
+
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return err
+		}
+		s.ProgessBar.Increment()
+
+		if rows++; rows >= batchSize {
+			if err := commit(); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+
+	if err := commit(); err != nil {
+		return err
+	}
+
+	// indexes are created after all rows are inserted since that is a lot faster
+	for _, index := range s.Indexes {
+		name := "idx_" + s.Table + "_" + index
+		_, err := db.ExecContext(ctx, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)",
+			sqliteQuote(name), sqliteQuote(s.Table), sqliteQuote(index)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// prepareTable creates the table or, in append mode, checks that an existing table has all columns with the same types.
+func (s SQLite) prepareTable(ctx context.Context, db *sql.DB, columns []sqliteColumn) error {
+	existing, err := sqliteTableColumns(ctx, db, s.Table)
+	if err != nil {
+		return err
+	}
+
+	if s.Append && len(existing) > 0 {
+		for _, column := range columns {
+			sqlType, ok := existing[column.name]
+			if !ok {
+				return fmt.Errorf("column %s is missing in table %s", column.name, s.Table)
+			}
+			if !strings.EqualFold(sqlType, column.sqlType) {
+				return fmt.Errorf("column %s of table %s has type %s instead of %s", column.name, s.Table, sqlType, column.sqlType)
+			}
+		}
+		return nil
+	}
+
+	if _, err := db.ExecContext(ctx, "DROP TABLE IF EXISTS "+sqliteQuote(s.Table)); err != nil {
+		return err
+	}
+
+	definitions := make([]string, len(columns))
+	for i, column := range columns {
+		definitions[i] = sqliteQuote(column.name) + " " + column.sqlType
+	}
+	_, err = db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s (%s)", sqliteQuote(s.Table), strings.Join(definitions, ", ")))
+	return err
+}
+
+// sqliteTableColumns returns the declared types of all columns of a table, which is empty if the table does not exist.
+func sqliteTableColumns(ctx context.Context, db *sql.DB, table string) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name, type FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := map[string]string{}
+	for rows.Next() {
+		var name, sqlType string
+		if err := rows.Scan(&name, &sqlType); err != nil {
+			return nil, err
+		}
+		columns[name] = sqlType
+	}
+
+	return columns, rows.Err()
+}
+
+// SQLiteTable derives a table name from an index name or pattern, e.g. "logs-*" becomes "logs".
+func SQLiteTable(index string) string {
+	table := strings.NewReplacer("*", "", "?", "", ",", "_").Replace(index)
+	table = strings.Trim(table, "-_.")
+	if table == "" {
+		return "documents"
+	}
+	return table
+}
+
+func sqliteType(kind valueKind) string {
+	switch kind {
+	case kindInt, kindBool:
+		return "INTEGER"
+	case kindFloat:
+		return "REAL"
+	default:
+		// dates are stored as ISO 8601 text that works with the SQLite date functions
+		return "TEXT"
+	}
+}
+
+func sqliteValue(val interface{}) interface{} {
+	switch val := val.(type) {
+	case time.Time:
+		return val.UTC().Format(time.RFC3339Nano)
+	case bool:
+		if val {
+			return int64(1)
+		}
+		return int64(0)
+	default:
+		return val
+	}
+}
+
+func sqliteQuote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func hasSQLiteColumn(columns []sqliteColumn, name string) bool {
+	for _, column := range columns {
+		if column.name == name {
+			return true
+		}
+	}
+	return false
+}
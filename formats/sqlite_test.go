@@ -0,0 +1,126 @@
+package formats
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+func TestSQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.db")
+	mapping := []elastic.Field{
+		{Name: "@timestamp", Type: "date"},
+		{Name: "bytes", Type: "long"},
+		{Name: "ok", Type: "boolean"},
+		{Name: "user", Type: elastic.TypeObject, Fields: []elastic.Field{{Name: "name", Type: "keyword"}}},
+	}
+
+	run := func(s SQLite, count int) error {
+		hits := make(chan elastic.SearchHit, count)
+		for i := 1; i <= count; i++ {
+			hits <- testHit{source: []byte(fmt.Sprintf(`{"@timestamp":"2026-10-17T13:45:00Z","bytes":%d,"ok":true,"user":{"name":"user%d"}}`, i*100, i))}
+		}
+		close(hits)
+
+		s.Path = path
+		s.ProgessBar = pb.New(count)
+		s.Table = "logs"
+		s.BatchSize = 2
+		return s.Run(context.Background(), hits)
+	}
+
+	if err := run(SQLite{Mapping: mapping, Indexes: []string{"user.name"}}, 3); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if err := run(SQLite{Mapping: mapping, Append: true}, 2); err != nil {
+		t.Fatalf("Run() with append error = %v", err)
+	}
+
+	incompatible := []elastic.Field{{Name: "bytes", Type: "keyword"}}
+	if err := run(SQLite{Mapping: incompatible, Append: true}, 1); err == nil {
+		t.Errorf("expected error appending to incompatible table")
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var count, sum, ok int
+	if err := db.QueryRow(`SELECT COUNT(*), SUM(bytes), SUM(ok) FROM logs`).Scan(&count, &sum, &ok); err != nil {
+		t.Fatal(err)
+	}
+	if count != 5 || sum != 900 || ok != 5 {
+		t.Errorf("unexpected table contents: count %d, sum %d, ok %d", count, sum, ok)
+	}
+
+	var timestamp string
+	if err := db.QueryRow(`SELECT datetime("@timestamp") FROM logs WHERE "user.name" = 'user2'`).Scan(&timestamp); err != nil {
+		t.Fatal(err)
+	}
+	if timestamp != "2026-10-17 13:45:00" {
+		t.Errorf("unexpected timestamp %s", timestamp)
+	}
+
+	var index string
+	if err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'index'`).Scan(&index); err != nil {
+		t.Fatal(err)
+	}
+	if index != "idx_logs_user.name" {
+		t.Errorf("unexpected index %s", index)
+	}
+}
+
+func TestSQLiteLargeIntegers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.db")
+
+	hits := make(chan elastic.SearchHit, 1)
+	hits <- testHit{source: []byte(`{"id":9007199254740993}`)}
+	close(hits)
+
+	s := SQLite{Path: path, ProgessBar: pb.New(1), Table: "logs", Mapping: []elastic.Field{{Name: "id", Type: "long"}}}
+	if err := s.Run(context.Background(), hits); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var id int64
+	if err := db.QueryRow(`SELECT id FROM logs`).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	if id != 9007199254740993 {
+		t.Errorf("expected id 9007199254740993, got %d", id)
+	}
+}
+
+func TestSQLiteTable(t *testing.T) {
+	tests := []struct {
+		index string
+		want  string
+	}{
+		{"logs", "logs"},
+		{"logs-*", "logs"},
+		{"logs-2026.10.*,metrics", "logs-2026.10._metrics"},
+		{"*", "documents"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.index, func(t *testing.T) {
+			if got := SQLiteTable(tt.index); got != tt.want {
+				t.Errorf("SQLiteTable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package formats
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+// Template renders every document with a Go text/template. The optional Header and Footer templates
+// are rendered once before the first and after the last document.
+type Template struct {
+	Outfile    io.Writer
+	ProgessBar *pb.ProgressBar
+	Warnings   *Warnings
+	Text       string
+	Header     string
+	Footer     string
+}
+
+// templateHit is the data a template is executed with.
+type templateHit struct {
+	ID      string
+	Index   string
+	Routing string
+	// Source is the document as nested objects, Fields the same document flattened to dotted names.
+	Source map[string]interface{}
+	Fields map[string]interface{}
+}
+
+func (t Template) Run(ctx context.Context, hits <-chan elastic.SearchHit) error {
+	tmpl, err := parseTemplate("template", t.Text)
+	if err != nil {
+		return err
+	}
+	header, err := parseTemplate("header", t.Header)
+	if err != nil {
+		return err
+	}
+	footer, err := parseTemplate("footer", t.Footer)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(t.Outfile)
+
+	if header != nil {
+		if err := header.Execute(w, nil); err != nil {
+			return err
+		}
+	}
+
+	// every document is rendered on its own, so documents the template fails on are skipped without partial output
+	var rendered bytes.Buffer
+
+	for hit := range hits {
+		var document map[string]interface{}
+		if err := json.Unmarshal(hit.GetSource(), &document); err != nil {
+			t.Warnings.Skip(1, "Error unmarshal JSON from ElasticSearch - %v", err)
+			continue
+		}
+
+		rendered.Reset()
+		err := tmpl.Execute(&rendered, templateHit{
+			ID:      hit.GetID(),
+			Index:   hit.GetIndex(),
+			Routing: hit.GetRouting(),
+			Source:  unflatten(document),
+			Fields:  flatten(document),
+		})
+		if err != nil {
+			// sparse documents miss fields the template uses, like index .Source.user "name" without user
+			t.Warnings.Skip(1, "Error rendering template for document %s - %v", hit.GetID(), err)
+			continue
+		}
+
+		if _, err := w.Write(rendered.Bytes()); err != nil {
+			return err
+		}
+		t.ProgessBar.Increment()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+
+	if footer != nil {
+		if err := footer.Execute(w, nil); err != nil {
+			return err
+		}
+	}
+
+	return w.Flush()
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	return template.New(name).Funcs(templateFuncs).Parse(text)
+}
+
+var templateFuncs = template.FuncMap{
+	"date":    templateDate,
+	"json":    templateJSON,
+	"csv":     templateCSV,
+	"sql":     templateSQL,
+	"default": templateDefault,
+}
+
+// templateDate formats a date value with a Go layout or returns a named part of it like year or weekday.
+func templateDate(layout string, val interface{}) string {
+	t, ok := parseTime(val)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprint(datePart(t, layout))
+}
+
+func templateJSON(val interface{}) (string, error) {
+	data, err := json.Marshal(val)
+	return string(data), err
+}
+
+// templateCSV quotes a value as CSV field if needed.
+func templateCSV(val interface{}) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write([]string{templateString(val)}); err != nil {
+		return "", err
+	}
+	w.Flush()
+	return strings.TrimSuffix(buf.String(), "\n"), w.Error()
+}
+
+// templateSQL returns a value as SQL literal, strings are quoted and missing values become NULL.
+func templateSQL(val interface{}) string {
+	switch val := val.(type) {
+	case nil:
+		return "NULL"
+	case bool:
+		if val {
+			return "TRUE"
+		}
+		return "FALSE"
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return "'" + strings.ReplaceAll(templateString(val), "'", "''") + "'"
+	}
+}
+
+// templateDefault returns val or def if val is missing or empty, so it can be used as {{.Source.name | default "-"}}.
+func templateDefault(def, val interface{}) interface{} {
+	switch v := val.(type) {
+	case nil:
+		return def
+	case string:
+		if v == "" {
+			return def
+		}
+	}
+	return val
+}
+
+func templateString(val interface{}) string {
+	switch val := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(val)
+		return string(data)
+	default:
+		return fmt.Sprint(val)
+	}
+}
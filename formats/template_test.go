@@ -0,0 +1,96 @@
+package formats
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+func TestTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template Template
+		want     string
+	}{
+		{
+			"log line",
+			Template{Text: `{{index .Source "@timestamp" | date "2006-01-02 15:04:05"}} {{.Source.host.name}} {{.Source.message}}` + "\n"},
+			"2026-10-17 13:45:00 web-1 it's up\n2026-10-17 13:46:00 web-2 \"down\", again\n",
+		},
+		{
+			"sql with header and footer",
+			Template{
+				Header: "BEGIN;\n",
+				Text:   `INSERT INTO logs VALUES ({{sql .ID}}, {{sql .Source.message}}, {{sql .Source.bytes}}, {{sql .Source.user}});` + "\n",
+				Footer: "COMMIT;\n",
+			},
+			"BEGIN;\nINSERT INTO logs VALUES ('1', 'it''s up', 512, NULL);\nINSERT INTO logs VALUES ('2', '\"down\", again', NULL, NULL);\nCOMMIT;\n",
+		},
+		{
+			"csv, json, default and fields",
+			Template{Text: `{{csv .Source.message}};{{json .Source.host}};{{.Source.bytes | default "-"}};{{index .Fields "host.name"}};{{.Index}}` + "\n"},
+			"it's up;{\"name\":\"web-1\"};512;web-1;logs\n\"\"\"down\"\", again\";{\"name\":\"web-2\"};-;web-2;logs\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out.txt")
+			outfile, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer outfile.Close()
+
+			hits := make(chan elastic.SearchHit, 2)
+			hits <- testHit{id: "1", index: "logs", source: []byte(`{"@timestamp":"2026-10-17T13:45:00Z","host":{"name":"web-1"},"message":"it's up","bytes":512}`)}
+			hits <- testHit{id: "2", index: "logs", source: []byte(`{"@timestamp":"2026-10-17T13:46:00Z","host.name":"web-2","message":"\"down\", again"}`)}
+			close(hits)
+
+			tmpl := tt.template
+			tmpl.Outfile = outfile
+			tmpl.ProgessBar = pb.New(2)
+			if err := tmpl.Run(context.Background(), hits); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Run() wrote %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateMissingKey(t *testing.T) {
+	hits := make(chan elastic.SearchHit, 3)
+	hits <- testHit{id: "1", source: []byte(`{"user":{"name":"jane"}}`)}
+	hits <- testHit{id: "2", source: []byte(`{"message":"no user"}`)}
+	hits <- testHit{id: "3", source: []byte(`{"user":{"name":"joe"}}`)}
+	close(hits)
+
+	var buf bytes.Buffer
+	bar := pb.New(3)
+	warnings := &Warnings{}
+	tmpl := Template{Outfile: &buf, ProgessBar: bar, Warnings: warnings, Text: `{{.ID}} {{index .Source.user "name"}}` + "\n"}
+	if err := tmpl.Run(context.Background(), hits); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// the document without user is skipped instead of failing the export
+	if want := "1 jane\n3 joe\n"; buf.String() != want {
+		t.Errorf("Run() wrote %q, want %q", buf.String(), want)
+	}
+	if bar.Current() != 2 || warnings.Skipped() != 1 {
+		t.Errorf("written %d, skipped %d", bar.Current(), warnings.Skipped())
+	}
+}
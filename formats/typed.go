@@ -0,0 +1,182 @@
+package formats
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// valueKind is the Go representation used by typed output formats for an ElasticSearch field type.
+type valueKind int
+
+const (
+	kindString valueKind = iota
+	kindInt
+	kindFloat
+	kindBool
+	kindTime
+)
+
+func kindOf(fieldType string) valueKind {
+	switch fieldType {
+	case "long", "integer", "short", "byte", "unsigned_long":
+		return kindInt
+	case "double", "float", "half_float", "scaled_float":
+		return kindFloat
+	case "boolean":
+		return kindBool
+	case "date", "date_nanos":
+		return kindTime
+	default:
+		return kindString
+	}
+}
+
+// decodeDocument decodes the source of a hit for typed output formats. Numbers are kept as json.Number
+// so long values above 2^53 are not rounded by a conversion to float64.
+func decodeDocument(source []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(source))
+	decoder.UseNumber()
+
+	var document map[string]interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// convertValue converts a value of a JSON document to the Go type of the given kind. Arrays are
+// written as JSON text to string columns, other columns take the first value, see arrayWarnings.
+// Values that can not be converted result in nil.
+func convertValue(kind valueKind, val interface{}) interface{} {
+	if values, ok := val.([]interface{}); ok {
+		switch {
+		case len(values) == 0:
+			return nil
+		case len(values) == 1 || kind != kindString:
+			val = values[0]
+		}
+	}
+
+	if val == nil {
+		return nil
+	}
+
+	switch kind {
+	case kindInt:
+		switch val := val.(type) {
+		case json.Number:
+			if i, err := val.Int64(); err == nil {
+				return i
+			}
+			if f, err := val.Float64(); err == nil {
+				return int64(f)
+			}
+		case float64:
+			return int64(val)
+		case string:
+			if i, err := strconv.ParseInt(val, 10, 64); err == nil {
+				return i
+			}
+			if f, err := strconv.ParseFloat(val, 64); err == nil {
+				return int64(f)
+			}
+		case bool:
+			if val {
+				return int64(1)
+			}
+			return int64(0)
+		}
+	case kindFloat:
+		switch val := val.(type) {
+		case json.Number:
+			if f, err := val.Float64(); err == nil {
+				return f
+			}
+		case float64:
+			return val
+		case string:
+			if f, err := strconv.ParseFloat(val, 64); err == nil {
+				return f
+			}
+		}
+	case kindBool:
+		switch val := val.(type) {
+		case bool:
+			return val
+		case string:
+			if b, err := strconv.ParseBool(val); err == nil {
+				return b
+			}
+		}
+	case kindTime:
+		if t, ok := parseTime(val); ok {
+			return t
+		}
+	default:
+		switch val := val.(type) {
+		case string:
+			return val
+		case json.Number:
+			return val.String()
+		case float64:
+			return strconv.FormatFloat(val, 'f', -1, 64)
+		case bool:
+			return strconv.FormatBool(val)
+		default:
+			data, err := json.Marshal(val)
+			if err != nil {
+				return fmt.Sprintf("%v", val)
+			}
+			return string(data)
+		}
+	}
+
+	return nil
+}
+
+// convertValues converts a single value or an array of a JSON document to values of the given kind
+// for list columns. Values that can not be converted are left out.
+func convertValues(kind valueKind, val interface{}) []interface{} {
+	values, ok := val.([]interface{})
+	if !ok {
+		values = []interface{}{val}
+	}
+
+	converted := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		if value = convertValue(kind, value); value != nil {
+			converted = append(converted, value)
+		}
+	}
+	return converted
+}
+
+// arrayWarnings logs once per field that an array with several values was written to a column that
+// is not a list, so only its first value is kept. Fields that hold arrays are marked in the mapping
+// with --array-fields.
+type arrayWarnings struct {
+	warnings *Warnings
+	logged   map[string]bool
+}
+
+func newArrayWarnings(warnings *Warnings) *arrayWarnings {
+	return &arrayWarnings{warnings: warnings, logged: map[string]bool{}}
+}
+
+// check logs a warning if val is an array whose values do not fit into the column of kind.
+func (a *arrayWarnings) check(name string, kind valueKind, val interface{}) {
+	if values, ok := val.([]interface{}); ok && len(values) > 1 && kind != kindString {
+		a.truncated(name)
+	}
+}
+
+// truncated logs the warning for a field whose array was cut to its first value.
+func (a *arrayWarnings) truncated(name string) {
+	if a.logged[name] {
+		return
+	}
+	a.logged[name] = true
+	a.warnings.Logf("Field %s holds arrays, only their first value is written, add it to --array-fields to keep all values", name)
+}
@@ -0,0 +1,34 @@
+package formats
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_convertValue(t *testing.T) {
+	// 9007199254740993 is 2^53+1 and can not be represented as float64
+	document, err := decodeDocument([]byte(`{"id":9007199254740993,"ratio":0.5,"text":9007199254740993,"ts":1760708700000,"big":18446744073709551615}`))
+	if err != nil {
+		t.Fatalf("decodeDocument() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		field string
+		kind  valueKind
+		want  interface{}
+	}{
+		{"long", "id", kindInt, int64(9007199254740993)},
+		{"double", "ratio", kindFloat, 0.5},
+		{"keyword", "text", kindString, "9007199254740993"},
+		{"epoch millis", "ts", kindTime, time.UnixMilli(1760708700000).UTC()},
+		{"unsigned long above int64", "big", kindFloat, 18446744073709551615.0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := convertValue(tt.kind, document[tt.field]); got != tt.want {
+				t.Errorf("convertValue() = %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
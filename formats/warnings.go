@@ -0,0 +1,66 @@
+package formats
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// maxWarnings is the number of warning messages that are kept, later ones are only counted.
+const maxWarnings = 100
+
+// Warnings counts the documents that formatters and sinks skip, like hits with invalid JSON or
+// batches that could not be sent, so an export can be reported as partial. Messages are logged to
+// Logger, or the standard logger if it is nil. All methods can be called on a nil *Warnings, which
+// only logs.
+type Warnings struct {
+	Logger   *log.Logger
+	mu       sync.Mutex
+	skipped  int64
+	messages []string
+}
+
+// Logf logs a message without counting skipped documents.
+func (w *Warnings) Logf(format string, args ...interface{}) {
+	if w == nil || w.Logger == nil {
+		log.Printf(format, args...)
+		return
+	}
+	w.Logger.Printf(format, args...)
+}
+
+// Skip logs the message and counts n skipped documents.
+func (w *Warnings) Skip(n int, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	w.Logf("%s", message)
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.skipped += int64(n)
+	if len(w.messages) < maxWarnings {
+		w.messages = append(w.messages, message)
+	}
+}
+
+// Skipped returns the number of skipped documents.
+func (w *Warnings) Skipped() int64 {
+	if w == nil {
+		return 0
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.skipped
+}
+
+// Messages returns the first warning messages.
+func (w *Warnings) Messages() []string {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.messages...)
+}
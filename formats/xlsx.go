@@ -0,0 +1,208 @@
+package formats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+const (
+	// xlsxMaxRows is the maximum number of rows of an Excel worksheet.
+	xlsxMaxRows = 1048576
+	// xlsxSampleRows is the number of documents that are used to calculate the column widths.
+	xlsxSampleRows = 100
+	xlsxMaxWidth   = 80
+	xlsxDateFormat = "yyyy-mm-dd hh:mm:ss"
+)
+
+// XLSX writes all documents into an Excel workbook with one typed column per leaf field of the index
+// mapping. Rows are streamed to disk, a new sheet is started when a sheet reaches MaxRows rows.
+type XLSX struct {
+	Outfile    io.Writer
+	ProgessBar *pb.ProgressBar
+	Warnings   *Warnings
+	Mapping    []elastic.Field
+	MaxRows    int
+}
+
+func (x XLSX) Run(ctx context.Context, hits <-chan elastic.SearchHit) error {
+	fields := elastic.LeafFields(x.Mapping)
+	if len(fields) == 0 {
+		return errors.New("no fields found in index mapping")
+	}
+
+	maxRows := x.MaxRows
+	if maxRows <= 1 || maxRows > xlsxMaxRows {
+		maxRows = xlsxMaxRows
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+
+	headerStyle, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+	dateFormat := xlsxDateFormat
+	dateStyle, err := f.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat})
+	if err != nil {
+		return err
+	}
+
+	header := make([]interface{}, len(fields))
+	widths := make([]float64, len(fields))
+	for i, field := range fields {
+		header[i] = excelize.Cell{StyleID: headerStyle, Value: field.Name}
+		widths[i] = xlsxWidth(field.Name)
+	}
+
+	var sw *excelize.StreamWriter
+	sheet, row := 0, 0
+
+	newSheet := func() error {
+		if sw != nil {
+			if err := sw.Flush(); err != nil {
+				return err
+			}
+		}
+
+		sheet++
+		name := fmt.Sprintf("Sheet%d", sheet)
+		if sheet > 1 {
+			if _, err := f.NewSheet(name); err != nil {
+				return err
+			}
+		}
+
+		sw, err = f.NewStreamWriter(name)
+		if err != nil {
+			return err
+		}
+		for i, width := range widths {
+			if err := sw.SetColWidth(i+1, i+1, width); err != nil {
+				return err
+			}
+		}
+		err = sw.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+		if err != nil {
+			return err
+		}
+
+		row = 1
+		return sw.SetRow("A1", header)
+	}
+
+	writeRow := func(values []interface{}) error {
+		if sw == nil || row >= maxRows {
+			if err := newSheet(); err != nil {
+				return err
+			}
+		}
+		row++
+		cell, err := excelize.CoordinatesToCellName(1, row)
+		if err != nil {
+			return err
+		}
+		return sw.SetRow(cell, values)
+	}
+
+	// the first documents are kept back until the column widths are known,
+	// since they can not be changed after rows have been streamed
+	sample := make([][]interface{}, 0, xlsxSampleRows)
+	writeSample := func() error {
+		for _, values := range sample {
+			if err := writeRow(values); err != nil {
+				return err
+			}
+		}
+		sample = nil
+		return nil
+	}
+
+	for hit := range hits {
+		var document map[string]interface{}
+		if err := json.Unmarshal(hit.GetSource(), &document); err != nil {
+			x.Warnings.Skip(1, "Error unmarshal JSON from ElasticSearch - %v", err)
+			continue
+		}
+
+		document = flatten(document)
+		values := make([]interface{}, len(fields))
+		for i, field := range fields {
+			val := convertValue(kindOf(field.Type), document[field.Name])
+			if t, ok := val.(time.Time); ok {
+				val = excelize.Cell{StyleID: dateStyle, Value: t}
+			}
+			values[i] = val
+		}
+
+		if sample != nil {
+			for i, val := range values {
+				if width := xlsxWidth(val); width > widths[i] {
+					widths[i] = width
+				}
+			}
+			sample = append(sample, values)
+			if len(sample) >= xlsxSampleRows {
+				if err := writeSample(); err != nil {
+					return err
+				}
+			}
+		} else if err := writeRow(values); err != nil {
+			return err
+		}
+		x.ProgessBar.Increment()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+
+	if err := writeSample(); err != nil {
+		return err
+	}
+	if sw == nil {
+		if err := newSheet(); err != nil {
+			return err
+		}
+	}
+	if err := sw.Flush(); err != nil {
+		return err
+	}
+
+	return f.Write(x.Outfile)
+}
+
+// xlsxWidth estimates the column width that is needed to display the given value.
+func xlsxWidth(val interface{}) float64 {
+	var width int
+	switch val := val.(type) {
+	case string:
+		width = utf8.RuneCountInString(val)
+	case int64:
+		width = len(strconv.FormatInt(val, 10))
+	case float64:
+		width = len(strconv.FormatFloat(val, 'f', -1, 64))
+	case bool:
+		width = len(strconv.FormatBool(val))
+	case excelize.Cell:
+		width = len(xlsxDateFormat)
+	}
+
+	if width > xlsxMaxWidth {
+		width = xlsxMaxWidth
+	}
+	return float64(width + 2)
+}
@@ -0,0 +1,84 @@
+package formats
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+	"gopkg.in/cheggaaa/pb.v2"
+
+	"github.com/pteich/elastic-query-export/elastic"
+)
+
+func TestXLSX(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.xlsx")
+	outfile, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hits := make(chan elastic.SearchHit, 5)
+	for i := 1; i <= 5; i++ {
+		hits <- testHit{source: []byte(fmt.Sprintf(`{"@timestamp":"2026-10-17T13:45:00Z","bytes":%d,"ok":true,"user":{"name":"user%d"}}`, i*100, i))}
+	}
+	close(hits)
+
+	x := XLSX{
+		Outfile:    outfile,
+		ProgessBar: pb.New(5),
+		Mapping: []elastic.Field{
+			{Name: "@timestamp", Type: "date"},
+			{Name: "bytes", Type: "long"},
+			{Name: "ok", Type: "boolean"},
+			{Name: "user", Type: elastic.TypeObject, Fields: []elastic.Field{{Name: "name", Type: "keyword"}}},
+		},
+		MaxRows: 3,
+	}
+	if err := x.Run(context.Background(), hits); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	outfile.Close()
+
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if sheets := f.GetSheetList(); !reflect.DeepEqual(sheets, []string{"Sheet1", "Sheet2", "Sheet3"}) {
+		t.Fatalf("unexpected sheets %v", sheets)
+	}
+
+	rows, err := f.GetRows("Sheet2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"@timestamp", "bytes", "ok", "user.name"},
+		{"2026-10-17 13:45:00", "300", "TRUE", "user3"},
+		{"2026-10-17 13:45:00", "400", "TRUE", "user4"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("GetRows() = %v, want %v", rows, want)
+	}
+
+	cellType, err := f.GetCellType("Sheet1", "B2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cellType != excelize.CellTypeNumber && cellType != excelize.CellTypeUnset {
+		t.Errorf("expected numeric cell, got %v", cellType)
+	}
+
+	panes, err := f.GetPanes("Sheet3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !panes.Freeze || panes.YSplit != 1 {
+		t.Errorf("expected frozen header row, got %+v", panes)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -15,18 +16,19 @@ import (
 
 var Version string
 
+// Exit codes of failed exports, 2 is used by the flag package for invalid arguments.
+const (
+	exitError      = 1
+	exitConnection = 3
+	exitAuth       = 4
+	exitQuery      = 5
+	exitPartial    = 6
+	exitWrite      = 7
+)
+
 func main() {
-	conf := flags.Flags{
-		ElasticURL:       "http://localhost:9200",
-		ElasticVerifySSL: false,
-		ElasticVersion:   7,
-		Index:            "logs-*",
-		Query:            "*",
-		OutFormat:        flags.FormatCSV,
-		Outfile:          "output.csv",
-		ScrollSize:       1000,
-		Timefield:        "@timestamp",
-	}
+	conf := flags.Defaults()
+	conf.Version = Version
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
 	defer cancel()
@@ -36,14 +38,32 @@ func main() {
 		"CLI tool to export data from ElasticSearch into a CSV or JSON file. https://github.com/pteich/elastic-query-export",
 		&conf,
 		func(c *configstruct.Command, cfg interface{}) error {
-			export.Run(ctx, cfg.(*flags.Flags))
-			return nil
+			_, err := export.New(export.WithFlags(cfg.(*flags.Flags)), export.WithSummarySignal()).Run(ctx)
+			return err
 		},
 	)
 
-	err := cmd.ParseAndRun(os.Args)
+	err := cmd.ParseAndRun(flags.JoinRepeated(os.Args))
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// exitCode maps the error classes of an export to exit codes, so scripts can detect failed exports.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, export.ErrConnection):
+		return exitConnection
+	case errors.Is(err, export.ErrAuth):
+		return exitAuth
+	case errors.Is(err, export.ErrQuery):
+		return exitQuery
+	case errors.Is(err, export.ErrPartial):
+		return exitPartial
+	case errors.Is(err, export.ErrWrite):
+		return exitWrite
+	default:
+		return exitError
 	}
 }